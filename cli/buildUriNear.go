@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"example.com/locations"
+	"example.com/openmeteo"
+)
+
+// nearCitiesCount is how many nearby cities --near lists.
+const nearCitiesCount = 5
+
+// BuildUriNear handles `--near lat,lon`: it lists the closest known cities
+// and returns the URI for the weather at the given coordinates.
+func BuildUriNear(near, lang string, units openmeteo.Units) string {
+	latStr, lonStr, found := strings.Cut(near, ",")
+
+	if !found {
+		fmt.Printf("\nInvalid --near value %q, expected lat,lon (e.g. 13.08,80.27)\n", near)
+		return ""
+	}
+
+	lat, lon, parseError := openmeteo.ParseCoordinates(latStr, lonStr)
+
+	if parseError != nil {
+		fmt.Printf("\n%v\n", parseError)
+		return ""
+	}
+
+	cities, loadError := locations.LoadCities()
+
+	if loadError != nil {
+		fmt.Println(loadError)
+		return ""
+	}
+
+	nearest := locations.NewIndex(cities).Nearest(lat, lon, nearCitiesCount)
+
+	fmt.Printf("\nClosest cities to %v, %v:\n", lat, lon)
+	for i, city := range nearest {
+		fmt.Printf("  %d. %-20s %8.1f km\n", i+1, city.DisplayName(lang), city.DistanceKm)
+	}
+
+	weatherApiUri := openmeteo.CurrentURLIn(openmeteo.ForecastURL, lat, lon, units)
+
+	fmt.Printf("\nThe URI to fetch: %s\n", weatherApiUri)
+
+	return weatherApiUri
+}
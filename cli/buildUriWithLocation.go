@@ -2,18 +2,16 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 
 	"example.com/locations"
+	"example.com/openmeteo"
 )
 
-var WEATHER_API_URI = "https://api.open-meteo.com/v1/forecast?"
-var REQUIRED_PARAMS = "&current=temperature_2m%2Crelative_humidity_2m%2Crain%2Cweather_code"
-
-func BuildUriWithLocation() string {
+func BuildUriWithLocation(lang string, units openmeteo.Units) string {
 	scanner := bufio.NewScanner(os.Stdin)
 
 	fmt.Printf("\nType in any Indian Metro City to get the weather: ")
@@ -29,21 +27,51 @@ func BuildUriWithLocation() string {
 
 	if cityFindError != nil {
 		fmt.Println(cityFindError)
+
+		if errors.Is(cityFindError, locations.ErrCityNotFound) {
+			printSuggestions(scanner.Text(), lang)
+		}
 		return ""
 	}
 
+	// e.g. "Chennai, Tamil Nadu, IN"; legacy city files only have the name
+	place := cityLocation.DisplayName(lang)
+	for _, part := range []string{cityLocation.Admin1, cityLocation.Country} {
+		if part != "" {
+			place += ", " + part
+		}
+	}
+
+	fmt.Printf("\nFound %s", place)
 	fmt.Printf("\nYour city's location is: %v, %v", cityLocation.Latitude, cityLocation.Longitude)
 
-	var weatherApiUriBuilder strings.Builder
+	// Build the URL with the package the server's provider uses, so the CLI
+	// and the server always ask for the same variables.
+	weatherApiUri := openmeteo.CurrentURLIn(openmeteo.ForecastURL, cityLocation.Latitude, cityLocation.Longitude, units)
+
+	fmt.Printf("\nThe URI to fetch: %s\n", weatherApiUri)
+
+	return weatherApiUri
+}
 
-	weatherApiUriBuilder.WriteString(WEATHER_API_URI)
-	weatherApiUriBuilder.WriteString("latitude=")
-	weatherApiUriBuilder.WriteString(strconv.FormatFloat(cityLocation.Latitude, 'f', -1, 64))
-	weatherApiUriBuilder.WriteString("&longitude=")
-	weatherApiUriBuilder.WriteString(strconv.FormatFloat(cityLocation.Longitude, 'f', -1, 64))
-	weatherApiUriBuilder.WriteString(REQUIRED_PARAMS)
+// printSuggestions lists known cities that closely match a name that wasn't found.
+func printSuggestions(city, lang string) {
+	cities, loadError := locations.LoadCities()
 
-	fmt.Printf("\nThe URI to fetch: %s\n", weatherApiUriBuilder.String())
+	if loadError != nil {
+		return
+	}
+
+	matches := locations.Search(cities, city, 5)
+
+	if len(matches) == 0 {
+		return
+	}
+
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.DisplayName(lang)
+	}
 
-	return weatherApiUriBuilder.String()
+	fmt.Printf("Did you mean: %s?\n", strings.Join(names, ", "))
 }
@@ -4,10 +4,11 @@ import (
 	"fmt"
 	"time"
 
+	"example.com/openmeteo"
 	weather_codes "example.com/weather_codes"
 )
 
-func DisplayWeatherDetails(w WeatherResponseBody) {
+func DisplayWeatherDetails(w WeatherResponseBody, lang string) {
 	fmt.Printf("Weather Details:\n")
 	fmt.Printf("  Latitude     : %.4f\n", w.Latitude)
 	fmt.Printf("  Longitude     : %.4f\n", w.Longitude)
@@ -26,6 +27,24 @@ func DisplayWeatherDetails(w WeatherResponseBody) {
 	}
 
 	fmt.Printf("  Interval      : %d %s\n", w.Current.Interval, w.CurrentUnits.Interval)
+	fmt.Printf("  Temperature   : %.1f %s\n", w.Current.Temperature, w.CurrentUnits.Temperature)
+	fmt.Printf("  Wind          : %.1f %s from %s (%.0f%s), gusts %.1f %s\n",
+		w.Current.WindSpeed, w.CurrentUnits.WindSpeed,
+		openmeteo.CompassPoint(float64(w.Current.WindDir)), w.Current.WindDir, w.CurrentUnits.WindDir,
+		w.Current.WindGusts, w.CurrentUnits.WindGusts)
+	fmt.Printf("  Dew Point     : %.1f %s\n", w.Current.DewPoint, w.CurrentUnits.DewPoint)
+	fmt.Printf("  Pressure      : %.1f %s\n", w.Current.Pressure, w.CurrentUnits.Pressure)
+	fmt.Printf("  Cloud Cover   : %.0f %s\n", w.Current.CloudCover, w.CurrentUnits.CloudCover)
+	fmt.Printf("  Visibility    : %.0f %s\n", w.Current.Visibility, w.CurrentUnits.Visibility)
+	fmt.Printf("  UV Index      : %.1f\n", w.Current.UVIndex)
 	fmt.Printf("  Rain      	: %f %s\n", w.Current.Rain, w.CurrentUnits.Rain)
-	fmt.Printf("  Weather Code  : %s\n", weather_codes.GetWeatherDescription(w.Current.Weather_Code))
+
+	weatherCode, codeError := weather_codes.GetWeatherCode(w.Current.Weather_Code, lang)
+
+	if codeError != nil {
+		fmt.Printf("  Weather Code  : Weather codes data unavailable!\n")
+		return
+	}
+
+	fmt.Printf("  Weather Code  : %s %s (%s)\n", weatherCode.Emoji, weatherCode.Description, weatherCode.Category)
 }
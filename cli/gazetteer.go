@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"example.com/locations"
+)
+
+// runGazetteer handles `weather-cli gazetteer <command> ...`.
+func runGazetteer(args []string) int {
+	if len(args) == 0 || args[0] != "import" {
+		fmt.Fprintln(os.Stderr, "usage: weather-cli gazetteer import [flags] <cities.txt|cities.csv>")
+		return 2
+	}
+	return runGazetteerImport(args[1:])
+}
+
+// runGazetteerImport converts a GeoNames dump into the gazetteer format.
+//
+//	weather-cli gazetteer import -country IN -min-population 100000 \
+//	    -admin1 admin1CodesASCII.txt -o ../locations/cities.json cities15000.txt
+func runGazetteerImport(args []string) int {
+	fs := flag.NewFlagSet("gazetteer import", flag.ContinueOnError)
+	format := fs.String("format", "", "input format: tsv (GeoNames dump) or csv; default from the file extension")
+	countries := fs.String("country", "", "comma-separated ISO country codes to keep, e.g. IN,LK (default all)")
+	minPopulation := fs.Int("min-population", 0, "drop places with fewer people")
+	featureCodes := fs.String("feature-codes", "", "comma-separated GeoNames feature codes to keep, e.g. PPLC,PPLA (default all populated places)")
+	admin1Path := fs.String("admin1", "", "GeoNames admin1CodesASCII.txt, to fill in region names")
+	maxAliases := fs.Int("max-aliases", locations.DefaultMaxAliases, "alternate names to keep per city")
+	output := fs.String("o", "-", "output gazetteer file, or - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: weather-cli gazetteer import [flags] <cities.txt|cities.csv>")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	inputPath := fs.Arg(0)
+
+	opts := locations.ImportOptions{
+		Format:        *format,
+		Countries:     splitList(*countries),
+		MinPopulation: *minPopulation,
+		FeatureCodes:  splitList(*featureCodes),
+		MaxAliases:    *maxAliases,
+	}
+	if opts.Format == "" {
+		opts.Format = locations.FormatTSV
+		if strings.EqualFold(filepath.Ext(inputPath), ".csv") {
+			opts.Format = locations.FormatCSV
+		}
+	}
+
+	if *admin1Path != "" {
+		admin1File, err := os.Open(*admin1Path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		opts.Admin1Names, err = locations.ReadAdmin1Codes(admin1File)
+		admin1File.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading %s: %v\n", *admin1Path, err)
+			return 1
+		}
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer input.Close()
+
+	cities, stats, err := locations.ImportGeoNames(input, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "importing %s: %v\n", inputPath, err)
+		return 1
+	}
+	if len(cities) == 0 {
+		fmt.Fprintln(os.Stderr, "no cities left after filtering; not writing an empty gazetteer")
+		return 1
+	}
+
+	if err := writeGazetteerTo(*output, cities); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "Read %d rows: imported %d cities, filtered %d, dropped %d duplicates\n",
+		stats.Read, stats.Imported, stats.Filtered, stats.Duplicates)
+	return 0
+}
+
+// writeGazetteerTo writes to stdout for "-", otherwise to a temp file that is
+// renamed over path, so a failed import never leaves a half-written file.
+func writeGazetteerTo(path string, cities map[string]locations.Location) error {
+	if path == "-" {
+		return locations.WriteGazetteer(os.Stdout, cities)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cities-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeAndClose(tmp, cities); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeAndClose(f *os.File, cities map[string]locations.Location) error {
+	if err := locations.WriteGazetteer(f, cities); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// splitList splits a comma-separated flag value, dropping blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
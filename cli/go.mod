@@ -1,11 +1,15 @@
-module weather-cli
+module weather-cli/cli
 
 go 1.24.4
 
-replace example.com/weather_codes => ./weather_codes
+replace example.com/weather_codes => ../weather_codes
 
-replace example.com/locations => ./locations
+replace example.com/locations => ../locations
+
+replace example.com/openmeteo => ../openmeteo
 
 require example.com/weather_codes v0.0.0-00010101000000-000000000000
 
 require example.com/locations v0.0.0-00010101000000-000000000000
+
+require example.com/openmeteo v0.0.0-00010101000000-000000000000
@@ -3,14 +3,56 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+
+	"example.com/locations"
+	"example.com/openmeteo"
+	weather_codes "example.com/weather_codes"
 )
 
 func main() {
-	// Get the input for city name & build the URI with Lat & Long
-	weatherApiUrl := BuildUriWithLocation()
+	// `weather-cli gazetteer import ...` manages the city list instead of fetching weather
+	if len(os.Args) > 1 && os.Args[1] == "gazetteer" {
+		os.Exit(runGazetteer(os.Args[2:]))
+	}
+
+	near := flag.String("near", "", "show the closest cities to `lat,lon` and the weather there")
+	citiesFile := flag.String("cities", os.Getenv("CITIES_FILE"), "gazetteer JSON to use instead of the built-in city list (env CITIES_FILE)")
+	weatherCodesFile := flag.String("weather-codes", os.Getenv("WEATHER_CODES_FILE"), "weather code descriptions JSON to use instead of the built-in table (env WEATHER_CODES_FILE)")
+	unitsFlag := flag.String("units", os.Getenv("WEATHER_UNITS"), "unit system: metric or imperial (env WEATHER_UNITS)")
+	temperatureUnit := flag.String("temperature-unit", "", "override the temperature unit: celsius or fahrenheit")
+	precipitationUnit := flag.String("precipitation-unit", "", "override the precipitation unit: mm or inch")
+	windSpeedUnit := flag.String("wind-speed-unit", "", "override the wind speed unit: kmh, ms, mph or kn")
+	langFlag := flag.String("lang", os.Getenv("LANG"), "language for weather descriptions and city names, e.g. hi or ta (env LANG)")
+	flag.Parse()
+
+	// Unsupported languages (and LANG=C) fall back to English
+	lang := weather_codes.MatchLocale(*langFlag)
+
+	units, unitsError := openmeteo.ParseUnits(*unitsFlag, *temperatureUnit, *precipitationUnit, *windSpeedUnit)
+
+	if unitsError != nil {
+		fmt.Println(unitsError)
+		os.Exit(2)
+	}
+
+	// Empty paths keep the data embedded in the binary
+	locations.CITIES_FILE_PATH = *citiesFile
+	weather_codes.WEATHER_CODES_FILE_PATH = *weatherCodesFile
+
+	var weatherApiUrl string
+
+	if *near != "" {
+		// --near lat,lon skips the prompt and uses the coordinates directly
+		weatherApiUrl = BuildUriNear(*near, lang, units)
+	} else {
+		// Get the input for city name & build the URI with Lat & Long
+		weatherApiUrl = BuildUriWithLocation(lang, units)
+	}
 
 	// If the Uri returned is empty, then the city was not found
 	if len(weatherApiUrl) == 0 {
@@ -46,5 +88,5 @@ func main() {
 
 	fmt.Println(data)
 
-	DisplayWeatherDetails(data)
+	DisplayWeatherDetails(data, lang)
 }
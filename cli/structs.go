@@ -16,6 +16,15 @@ type Current struct {
 	Rain         float32 `json:"rain"`
 	Weather_Code float32 `json:"weather_code"`
 	RelHumidity  float32 `json:"relative_humidity_2m"`
+	Is_Day       int     `json:"is_day"`
+	WindSpeed    float32 `json:"wind_speed_10m"`
+	WindGusts    float32 `json:"wind_gusts_10m"`
+	WindDir      float32 `json:"wind_direction_10m"`
+	Pressure     float32 `json:"surface_pressure"`
+	CloudCover   float32 `json:"cloud_cover"`
+	Visibility   float32 `json:"visibility"`
+	DewPoint     float32 `json:"dew_point_2m"`
+	UVIndex      float32 `json:"uv_index"`
 }
 
 type CurrentUnits struct {
@@ -25,4 +34,13 @@ type CurrentUnits struct {
 	Rain         string `json:"rain"`
 	Weather_Code string `json:"weather_code"`
 	RelHumidity  string `json:"relative_humidity_2m"`
+	Is_Day       string `json:"is_day"`
+	WindSpeed    string `json:"wind_speed_10m"`
+	WindGusts    string `json:"wind_gusts_10m"`
+	WindDir      string `json:"wind_direction_10m"`
+	Pressure     string `json:"surface_pressure"`
+	CloudCover   string `json:"cloud_cover"`
+	Visibility   string `json:"visibility"`
+	DewPoint     string `json:"dew_point_2m"`
+	UVIndex      string `json:"uv_index"`
 }
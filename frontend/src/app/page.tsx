@@ -1,7 +1,7 @@
 "use client"
 
-import { useState } from "react"
-import { WeatherResp } from "@/types/responses"
+import { useEffect, useState } from "react"
+import { BatchResp, LocationSearchResp, WeatherResp } from "@/types/responses"
 import Favorites from "@/components/Favourites"
 import { Skeleton } from "@/components/ui/skeleton"
 import WeatherCard from "@/components/WeatherCard"
@@ -15,27 +15,84 @@ export default function Page() {
 	const [loading, setLoading] = useState(false)
 	const [error, setError] = useState<string | null>(null)
 	const [data, setData] = useState<WeatherResp | null>(null)
+	const [suggestions, setSuggestions] = useState<string[]>([])
+	const [didYouMean, setDidYouMean] = useState<string[]>([])
 
 	const theme = useTheme(data?.is_day ?? 1)
 
-	// Temp conversion flag
+	// Unit system to ask the server for: °C/mm/km/h or °F/inch/mph
 	const [unit, setUnit] = useState<"C" | "F">("C")
 
 	// Use the custom hook for favorites management
 	const { favorites, removeFavorite, isFull } = useFavourites()
 
+	// Autocomplete: ask the server for matching cities as the user types
+	useEffect(() => {
+		const q = city.trim()
+		if (q.length < 2) {
+			setSuggestions([])
+			return
+		}
+		const timer = setTimeout(async () => {
+			try {
+				const res = await fetch(`http://localhost:8080/locations/search?q=${encodeURIComponent(q)}&limit=8`)
+				if (!res.ok) return
+				const j = (await res.json()) as LocationSearchResp
+				setSuggestions(j.results.map((m) => m.name))
+			} catch { }
+		}, 200)
+		return () => clearTimeout(timer)
+	}, [city])
+
+	// Current temperature of every favourite, fetched in one batch request
+	const [favoriteTemps, setFavoriteTemps] = useState<Record<string, string>>({})
+	useEffect(() => {
+		if (favorites.length === 0) {
+			setFavoriteTemps({})
+			return
+		}
+		const units = unit === "F" ? "imperial" : "metric"
+		fetch(`http://localhost:8080/weather/batch?units=${units}&fields=temperature`, {
+			method: "POST",
+			headers: { "Content-Type": "application/json" },
+			body: JSON.stringify({ cities: favorites }),
+		})
+			.then((res) => (res.ok ? (res.json() as Promise<BatchResp>) : null))
+			.then((j) => {
+				if (!j) return
+				const temps: Record<string, string> = {}
+				j.results.forEach((item, i) => {
+					// A city that failed just shows without a temperature
+					if (item.status === 200 && item.weather?.temperature !== undefined) {
+						temps[favorites[i]] = `${Math.round(item.weather.temperature)}${item.weather.units?.temperature ?? ""}`
+					}
+				})
+				setFavoriteTemps(temps)
+			})
+			.catch(() => { })
+	}, [favorites, unit])
+
+	// Ask again in the new units when the °C/°F switch is flipped
+	useEffect(() => {
+		if (data) fetchWeatherFor(data.city)
+		// eslint-disable-next-line react-hooks/exhaustive-deps
+	}, [unit])
+
 	async function fetchWeatherFor(qcity?: string) {
 		const queryCity = (qcity ?? city).trim()
 		setError(null)
+		setDidYouMean([])
 		setLoading(true)
 		try {
 			const encoded = encodeURIComponent(queryCity || "")
-			const res = await fetch(`http://localhost:8080/weather?city=${encoded}`)
+			const units = unit === "F" ? "imperial" : "metric"
+			const res = await fetch(`http://localhost:8080/weather?city=${encoded}&units=${units}`)
 			if (res.status === 404) {
 				let txt = "City not found"
 				try {
 					const j = await res.json()
 					if (j && typeof j.error === "string") txt = j.error
+					if (j && Array.isArray(j.did_you_mean)) setDidYouMean(j.did_you_mean)
 				} catch { }
 				setError(txt)
 				return
@@ -74,11 +131,17 @@ export default function Page() {
 
 				<form onSubmit={onSubmit} className="flex gap-3 items-center">
 					<input
+						list="city-suggestions"
 						value={city}
 						onChange={(e) => setCity(e.target.value)}
 						placeholder="Enter city (e.g. chennai)"
 						className="flex-1 px-3 py-2 rounded border border-slate-300 dark:border-slate-700 bg-white dark:bg-slate-800"
 					/>
+					<datalist id="city-suggestions">
+						{suggestions.map((s) => (
+							<option key={s} value={s} />
+						))}
+					</datalist>
 					<button
 						type="submit"
 						disabled={loading}
@@ -90,6 +153,7 @@ export default function Page() {
 
 				<Favorites
 					favorites={favorites}
+					temps={favoriteTemps}
 					onFetch={(c) => fetchWeatherFor(c)}
 					onRemove={(c) => removeFavorite(c)}
 					isFull={isFull}
@@ -114,11 +178,26 @@ export default function Page() {
 					{!loading && error && (
 						<div className="text-red-700 bg-red-50 dark:bg-red-900/30 p-3 rounded">
 							{error}
+							{didYouMean.length > 0 && (
+								<div className="mt-2 text-sm">
+									Did you mean:{" "}
+									{didYouMean.map((s) => (
+										<button
+											key={s}
+											type="button"
+											onClick={() => fetchWeatherFor(s)}
+											className="mr-2 underline cursor-pointer"
+										>
+											{s}
+										</button>
+									))}
+								</div>
+							)}
 						</div>
 					)}
 
 					{!loading && !error && data && (
-						<WeatherCard data={data} unit={unit} />
+						<WeatherCard data={data} />
 					)}
 					{!loading && !error && !data && (
 						<div className="mt-4 text-sm text-slate-500">No data yet — ask for weather.</div>
@@ -5,12 +5,14 @@ import Tags from "./ui/tag"
 
 type Props = {
     favorites: string[]
+    // Current temperature per city, e.g. { chennai: "30°C" }
+    temps?: Record<string, string>
     onFetch: (city: string) => void
     onRemove: (city: string) => void
     isFull?: boolean
 }
 
-export default function Favorites({ favorites, onFetch, onRemove, isFull }: Props) {
+export default function Favorites({ favorites, temps, onFetch, onRemove, isFull }: Props) {
     if (!favorites || favorites.length === 0) return null
 
     return (
@@ -19,7 +21,7 @@ export default function Favorites({ favorites, onFetch, onRemove, isFull }: Prop
                 <Tags
                     onRemove={onRemove}
                     onClick={(id) => onFetch(id)}
-                    tags={favorites.map(city => ({ id: city, label: city }))}
+                    tags={favorites.map(city => ({ id: city, label: temps?.[city] ? `${city} · ${temps[city]}` : city }))}
                 />
             </div>
             {isFull && (
@@ -1,26 +1,23 @@
 import { Card, CardContent, CardFooter, CardHeader, CardTitle } from "@/components/ui/card"
 import { WeatherResp } from "@/types/responses"
-import { Clock, Cloud, Droplet, MapPin } from "lucide-react"
+import { Clock, Droplet, MapPin, SunDim, Wind } from "lucide-react"
 import { Button } from "./ui/button"
 import { Badge } from "./ui/badge"
 import useFavourites from "@/hooks/useFavourites"
+import WeatherIcon from "./WeatherIcon"
 
 type Props = {
     data: WeatherResp
-    unit?: "C" | "F"
-}
-
-function toF(c: number) {
-    return (c * 9) / 5 + 32
 }
 
 export default function WeatherCard(props: Props) {
-    const { data, unit } = props;
+    const { data } = props;
     const { isFavorite, addFavorite, isFull } = useFavourites()
 
-    const temp = unit === "F" ? toF(data.temp_c) : data.temp_c
-    const apparent_temperature = unit === "F" ? toF(data.apparent_temperature) : data.apparent_temperature
-    const label = unit ?? "C"
+    // The server converts values to the requested units and labels them
+    const temp = data.temperature
+    const apparent_temperature = data.apparent_temperature
+    const label = data.units?.temperature ?? "°C"
     const saved = isFavorite(data.city)
     const cannotSave = saved || (isFull && !saved)
 
@@ -60,7 +57,7 @@ export default function WeatherCard(props: Props) {
                         <div className="z-10 text-center">
                             <div className="text-3xl font-bold leading-none">
                                 {Math.round(temp)}
-                                <span className="text-base font-medium">°{label}</span>
+                                <span className="text-base font-medium">{label}</span>
                             </div>
                             <div className="text-xs text-slate-500 mt-1">Feels like {Math.round(apparent_temperature)}°</div>
                         </div>
@@ -96,7 +93,7 @@ export default function WeatherCard(props: Props) {
             <CardContent className="pt-2">
                 <div className="grid grid-cols-2 gap-4">
                     <div className="flex items-start gap-3">
-                        <Cloud className="mt-1 text-slate-500" />
+                        <WeatherIcon icon={data.icon} className="mt-1 text-slate-500" />
                         <div>
                             <div className="text-xs text-slate-500">Condition</div>
                             <div className="font-medium">{data.description}</div>
@@ -126,6 +123,27 @@ export default function WeatherCard(props: Props) {
                             <div className="font-mono">{data.precipitation_probability ?? 0} %</div>
                         </div>
                     </div>
+
+                    <div className="flex items-start gap-3">
+                        <Wind className="mt-1 text-slate-500" />
+                        <div>
+                            <div className="text-xs text-slate-500">Wind</div>
+                            <div className="font-mono">
+                                {data.wind_speed ?? "—"} {data.units?.wind_speed} {data.wind_direction_compass ?? ""}
+                            </div>
+                            {data.wind_gusts !== undefined && (
+                                <div className="text-xs text-slate-500">gusts {data.wind_gusts} {data.units?.wind_speed}</div>
+                            )}
+                        </div>
+                    </div>
+
+                    <div className="flex items-start gap-3">
+                        <SunDim className="mt-1 text-slate-500" />
+                        <div>
+                            <div className="text-xs text-slate-500">UV Index</div>
+                            <div className="font-mono">{data.uv_index ?? "—"}</div>
+                        </div>
+                    </div>
                 </div>
             </CardContent>
 
@@ -0,0 +1,55 @@
+import {
+    Cloud,
+    CloudDrizzle,
+    CloudFog,
+    CloudHail,
+    CloudLightning,
+    CloudMoon,
+    CloudMoonRain,
+    CloudRain,
+    CloudRainWind,
+    CloudSnow,
+    CloudSun,
+    CloudSunRain,
+    LucideProps,
+    Moon,
+    Snowflake,
+    Sun,
+} from "lucide-react"
+
+// The server decides which icon a weather code gets (see weather_codes/data.json);
+// this only says how each icon id is drawn.
+const icons: Record<string, React.ComponentType<LucideProps>> = {
+    "clear-day": Sun,
+    "clear-night": Moon,
+    "mostly-clear-day": CloudSun,
+    "mostly-clear-night": CloudMoon,
+    "partly-cloudy-day": CloudSun,
+    "partly-cloudy-night": CloudMoon,
+    "overcast": Cloud,
+    "fog": CloudFog,
+    "rime-fog": CloudFog,
+    "drizzle": CloudDrizzle,
+    "freezing-drizzle": CloudDrizzle,
+    "rain": CloudRain,
+    "heavy-rain": CloudRainWind,
+    "freezing-rain": CloudHail,
+    "showers-day": CloudSunRain,
+    "showers-night": CloudMoonRain,
+    "snow": CloudSnow,
+    "heavy-snow": Snowflake,
+    "snow-grains": CloudSnow,
+    "snow-showers-day": CloudSnow,
+    "snow-showers-night": CloudSnow,
+    "thunderstorm": CloudLightning,
+    "thunderstorm-hail": CloudHail,
+}
+
+type Props = LucideProps & {
+    icon?: string
+}
+
+export default function WeatherIcon({ icon, ...props }: Props) {
+    const Icon = (icon && icons[icon]) || Cloud
+    return <Icon {...props} />
+}
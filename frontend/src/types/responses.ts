@@ -1,8 +1,24 @@
+export type WeatherCategory = "clear" | "cloud" | "fog" | "drizzle" | "rain" | "snow" | "storm";
+
+// Display symbols for the unit of each quantity, e.g. "°C", "mm", "km/h"
+export interface Units {
+    temperature: string;
+    precipitation: string;
+    wind_speed: string;
+    pressure?: string;
+    visibility?: string;
+}
+
 export interface WeatherResp {
     city: string;
-    temp_c: number;
+    country?: string;
+    timezone?: string;
+    temperature: number;
     apparent_temperature: number;
     description: string;
+    weather_code?: number;
+    category?: WeatherCategory;
+    icon?: string;
     time: string;
     lat?: number;
     lon?: number;
@@ -10,4 +26,50 @@ export interface WeatherResp {
     rain?: number;
     precipitation_probability?: number;
     is_day?: 1 | 0;
+    wind_speed?: number;
+    wind_gusts?: number;
+    wind_direction?: number;
+    wind_direction_compass?: string;
+    surface_pressure?: number;
+    cloud_cover?: number;
+    visibility?: number;
+    dew_point?: number;
+    uv_index?: number;
+    units: Units;
+}
+
+// One entry of GET /weather?city=a&city=b or POST /weather/batch
+export interface BatchItem {
+    query: string;
+    status: number;
+    weather?: Partial<WeatherResp>;
+    error?: string;
+    did_you_mean?: string[];
+}
+
+export interface BatchResp {
+    results: BatchItem[];
+}
+
+export interface Location {
+    name: string;
+    names?: Record<string, string>;
+    aliases?: string[];
+    country?: string;
+    admin1?: string;
+    timezone?: string;
+    latitude: number;
+    longitude: number;
+    elevation?: number;
+    population?: number;
+}
+
+export interface LocationMatch extends Location {
+    id: string;
+    score: number;
+}
+
+export interface LocationSearchResp {
+    query: string;
+    results: LocationMatch[];
 }
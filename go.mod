@@ -1,8 +1,19 @@
 module weather-cli
 
-go 1.19
+go 1.24.4
 
-require github.com/redis/go-redis/v9 v9.14.0
+replace example.com/locations => ./locations
+
+replace example.com/weather_codes => ./weather_codes
+
+replace example.com/openmeteo => ./openmeteo
+
+require (
+	example.com/locations v0.0.0-00010101000000-000000000000
+	example.com/openmeteo v0.0.0-00010101000000-000000000000
+	example.com/weather_codes v0.0.0-00010101000000-000000000000
+	github.com/redis/go-redis/v9 v9.14.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
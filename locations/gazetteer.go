@@ -0,0 +1,248 @@
+package locations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SchemaVersion is the current cities.json format:
+//
+//	{
+//	  "version": 2,
+//	  "cities": {
+//	    "mumbai": {"name": "Mumbai", "aliases": ["Bombay"], "country": "IN", ...}
+//	  }
+//	}
+//
+// Files without a version are read as the legacy format: either a map of
+// snake_case names to {latitude, longitude}, or an array of
+// {name|city, lat|latitude, lon|longitude} objects.
+const SchemaVersion = 2
+
+// Gazetteer is a parsed cities file: every known city keyed by its ID (the
+// snake_case key, e.g. "new_york"), plus a lookup table of names and aliases.
+type Gazetteer struct {
+	Version int                 `json:"version"`
+	Cities  map[string]Location `json:"cities"`
+
+	names map[string]string // normalized ID, name, alias or localized name -> ID
+}
+
+// NewGazetteer builds a Gazetteer from cities keyed by ID. Cities without a
+// display name get one derived from their ID ("new_york" -> "New York").
+func NewGazetteer(cities map[string]Location) *Gazetteer {
+	g := &Gazetteer{
+		Version: SchemaVersion,
+		Cities:  make(map[string]Location, len(cities)),
+		names:   make(map[string]string, len(cities)),
+	}
+	for id, loc := range cities {
+		if loc.Name == "" {
+			loc.Name = displayNameFromID(id)
+		}
+		g.Cities[id] = loc
+	}
+
+	// A city's ID always finds it, then its display name, then its aliases:
+	// a name of a stronger kind is never taken over by a weaker one. When two
+	// cities claim the same kind of name, the more populous city gets it, then
+	// the smaller ID, so lookups never depend on map iteration order.
+	ranks := make(map[string]nameRank, len(cities))
+	claim := func(key, id string, rank nameRank) {
+		if other, taken := g.names[key]; taken {
+			if ranks[key] < rank || (ranks[key] == rank && !g.preferred(id, other)) {
+				return
+			}
+		}
+		g.names[key] = id
+		ranks[key] = rank
+	}
+	for id, loc := range g.Cities {
+		claim(NormalizeName(id), id, rankID)
+		claim(NormalizeName(loc.Name), id, rankDisplayName)
+		for _, alias := range loc.allAliases() {
+			claim(NormalizeName(alias), id, rankAlias)
+		}
+	}
+	return g
+}
+
+// nameRank orders the kinds of name a city can be looked up by, strongest first.
+type nameRank int
+
+const (
+	rankID nameRank = iota
+	rankDisplayName
+	rankAlias
+)
+
+// preferred reports whether city a should get a contested name over city b:
+// the more populous one, or the smaller ID when populations are equal.
+func (g *Gazetteer) preferred(a, b string) bool {
+	pa, pb := g.Cities[a].Population, g.Cities[b].Population
+	if pa != pb {
+		return pa > pb
+	}
+	return a < b
+}
+
+// LoadGazetteer reads and parses a cities file.
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGazetteer(data)
+}
+
+// ParseGazetteer parses a cities file in the current or legacy format.
+// Entries with out-of-range coordinates make the whole file invalid.
+func ParseGazetteer(data []byte) (*Gazetteer, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("gazetteer: empty file")
+	}
+
+	var cities map[string]Location
+	var err error
+	if data[0] == '[' {
+		cities, err = parseLegacyArray(data)
+	} else {
+		var head struct {
+			Version int `json:"version"`
+		}
+		if err := json.Unmarshal(data, &head); err != nil {
+			return nil, fmt.Errorf("gazetteer: %w", err)
+		}
+
+		switch {
+		case head.Version == 0:
+			cities, err = parseLegacyMap(data)
+		case head.Version > SchemaVersion:
+			return nil, fmt.Errorf("gazetteer: unsupported version %d (newest known is %d)", head.Version, SchemaVersion)
+		default:
+			var file Gazetteer
+			err = json.Unmarshal(data, &file)
+			cities = file.Cities
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gazetteer: %w", err)
+	}
+
+	if len(cities) == 0 {
+		return nil, fmt.Errorf("gazetteer: no cities found")
+	}
+	for id, loc := range cities {
+		if strings.TrimSpace(id) == "" {
+			return nil, fmt.Errorf("gazetteer: city with an empty ID")
+		}
+		if loc.Latitude < -90 || loc.Latitude > 90 || loc.Longitude < -180 || loc.Longitude > 180 {
+			return nil, fmt.Errorf("gazetteer: %s has out-of-range coordinates %g,%g", id, loc.Latitude, loc.Longitude)
+		}
+	}
+	return NewGazetteer(cities), nil
+}
+
+// legacyEntry tolerates the field spellings found in older cities files
+// (name/city, lat/latitude, lon/longitude).
+type legacyEntry struct {
+	Name      string  `json:"name"`
+	City      string  `json:"city"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+}
+
+func (e legacyEntry) location() (Location, bool) {
+	lat, lon := e.Latitude, e.Longitude
+	if e.Lat != 0 {
+		lat = e.Lat
+	}
+	if e.Lon != 0 {
+		lon = e.Lon
+	}
+	// Entries without coordinates were always skipped
+	return Location{Latitude: lat, Longitude: lon}, lat != 0 || lon != 0
+}
+
+// parseLegacyMap reads { "city_name": {"latitude": .., "longitude": ..}, ... }.
+func parseLegacyMap(data []byte) (map[string]Location, error) {
+	var raw map[string]legacyEntry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	cities := make(map[string]Location, len(raw))
+	for name, e := range raw {
+		if loc, ok := e.location(); ok {
+			cities[legacyID(name)] = loc
+		}
+	}
+	return cities, nil
+}
+
+// parseLegacyArray reads [ {"name": .., "lat": .., "lon": ..}, ... ].
+func parseLegacyArray(data []byte) (map[string]Location, error) {
+	var raw []legacyEntry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	cities := make(map[string]Location, len(raw))
+	for _, e := range raw {
+		name := e.Name
+		if strings.TrimSpace(name) == "" {
+			name = e.City
+		}
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		if loc, ok := e.location(); ok {
+			cities[legacyID(name)] = loc
+		}
+	}
+	return cities, nil
+}
+
+// legacyID turns a legacy city name into a snake_case ID.
+func legacyID(name string) string {
+	return strings.ReplaceAll(NormalizeName(name), " ", "_")
+}
+
+// displayNameFromID title-cases an ID: "new_york" -> "New York".
+func displayNameFromID(id string) string {
+	words := strings.Fields(NormalizeName(id))
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = strings.ToUpper(string(r[0])) + string(r[1:])
+	}
+	return strings.Join(words, " ")
+}
+
+// Len returns the number of cities.
+func (g *Gazetteer) Len() int {
+	return len(g.Cities)
+}
+
+// Lookup finds a city by ID, display name or alias, ignoring case and
+// punctuation. "Mumbai", "bombay" and "Mumbai, IN" all find "mumbai".
+// Returns the city's ID and Location.
+func (g *Gazetteer) Lookup(name string) (string, Location, bool) {
+	// Try the whole name, then just the part before a comma
+	candidates := []string{name}
+	if first, _, found := strings.Cut(name, ","); found {
+		candidates = append(candidates, first)
+	}
+
+	for _, c := range candidates {
+		if id, ok := g.names[NormalizeName(c)]; ok {
+			return id, g.Cities[id], true
+		}
+	}
+	return "", Location{}, false
+}
@@ -0,0 +1,452 @@
+package locations
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Import formats accepted by ImportGeoNames.
+const (
+	// FormatTSV is a GeoNames dump (cities15000.txt etc.): 19 tab-separated
+	// columns, no header. See https://download.geonames.org/export/dump/readme.txt
+	FormatTSV = "tsv"
+	// FormatCSV is a CSV export with a header row naming the GeoNames columns
+	// ("name", "ascii_name", "country_code", ...). ',' and ';' both work.
+	FormatCSV = "csv"
+)
+
+// DefaultMaxAliases is how many alternate names an imported city keeps.
+const DefaultMaxAliases = 10
+
+// duplicateRadiusKm is how close two same-named places in one country must
+// be for the smaller one to be dropped as a duplicate (GeoNames often lists
+// a city and its administrative seat separately).
+const duplicateRadiusKm = 25
+
+// ImportOptions filters and shapes a GeoNames import.
+type ImportOptions struct {
+	// Format is FormatTSV or FormatCSV.
+	Format string
+	// Countries keeps only these ISO country codes. Empty keeps all.
+	Countries []string
+	// MinPopulation drops smaller places.
+	MinPopulation int
+	// FeatureCodes keeps only these GeoNames feature codes (PPLC, PPLA, ...).
+	// Empty keeps every populated place (feature class P).
+	FeatureCodes []string
+	// Admin1Names maps "CC.code" (e.g. "IN.25") to a region name, as read by
+	// ReadAdmin1Codes. Without it imported cities have no region.
+	Admin1Names map[string]string
+	// MaxAliases caps the alternate names kept per city (0 means DefaultMaxAliases).
+	MaxAliases int
+}
+
+// ImportStats summarizes what ImportGeoNames did with each row.
+type ImportStats struct {
+	Read       int // data rows read
+	Filtered   int // dropped by country / population / feature code
+	Duplicates int // dropped as a duplicate of a bigger place nearby
+	Imported   int // cities in the result
+}
+
+// geoRecord is one GeoNames row, whatever file format it came from.
+type geoRecord struct {
+	id             string
+	name           string
+	asciiName      string
+	alternateNames []string
+	lat, lon       float64
+	featureClass   string
+	featureCode    string
+	country        string
+	admin1Code     string
+	population     int
+	elevation      float64
+	timezone       string
+}
+
+// ImportGeoNames reads a GeoNames dump and returns gazetteer cities keyed by
+// ID, ready for WriteGazetteer.
+//
+// IDs are the snake_case ASCII name ("sao_paulo"). When several places share
+// a name, the most populous keeps the plain ID and the others get their
+// country (and if needed region) appended: "hyderabad" and "hyderabad_pk".
+func ImportGeoNames(r io.Reader, opts ImportOptions) (map[string]Location, ImportStats, error) {
+	var stats ImportStats
+
+	var records []geoRecord
+	var err error
+	switch opts.Format {
+	case FormatTSV, "":
+		records, err = readGeoNamesTSV(r)
+	case FormatCSV:
+		records, err = readGeoNamesCSV(r)
+	default:
+		return nil, stats, fmt.Errorf("unknown import format %q (want %s or %s)", opts.Format, FormatTSV, FormatCSV)
+	}
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.Read = len(records)
+
+	countries := upperSet(opts.Countries)
+	features := upperSet(opts.FeatureCodes)
+	kept := records[:0]
+	for _, rec := range records {
+		switch {
+		case len(countries) > 0 && !countries[strings.ToUpper(rec.country)]:
+		case rec.population < opts.MinPopulation:
+		case len(features) > 0 && !features[strings.ToUpper(rec.featureCode)]:
+		case len(features) == 0 && rec.featureClass != "" && rec.featureClass != "P":
+		default:
+			kept = append(kept, rec)
+			continue
+		}
+		stats.Filtered++
+	}
+
+	// Biggest first, so the best-known place gets the plain ID
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].population > kept[j].population })
+
+	maxAliases := opts.MaxAliases
+	if maxAliases <= 0 {
+		maxAliases = DefaultMaxAliases
+	}
+
+	cities := make(map[string]Location, len(kept))
+	byBase := make(map[string][]Location) // base ID -> cities already imported under it
+	for _, rec := range kept {
+		base := legacyID(firstNonBlank(rec.asciiName, rec.name))
+		if base == "" {
+			stats.Filtered++
+			continue
+		}
+		if isDuplicate(byBase[base], rec) {
+			stats.Duplicates++
+			continue
+		}
+
+		loc := Location{
+			Name:       rec.name,
+			Aliases:    importAliases(rec, maxAliases),
+			Country:    strings.ToUpper(rec.country),
+			Admin1:     opts.Admin1Names[strings.ToUpper(rec.country)+"."+rec.admin1Code],
+			Timezone:   rec.timezone,
+			Latitude:   rec.lat,
+			Longitude:  rec.lon,
+			Elevation:  rec.elevation,
+			Population: rec.population,
+		}
+		cities[uniqueID(cities, base, rec)] = loc
+		byBase[base] = append(byBase[base], loc)
+	}
+
+	stats.Imported = len(cities)
+	return cities, stats, nil
+}
+
+// isDuplicate reports whether rec is a smaller copy of a same-named place
+// already imported in the same country.
+func isDuplicate(existing []Location, rec geoRecord) bool {
+	for _, loc := range existing {
+		if strings.EqualFold(loc.Country, rec.country) &&
+			DistanceKm(loc.Latitude, loc.Longitude, rec.lat, rec.lon) <= duplicateRadiusKm {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueID picks the first free ID of base, base_cc, base_cc_admin1, base_<geonameid>.
+func uniqueID(cities map[string]Location, base string, rec geoRecord) string {
+	cc := strings.ToLower(rec.country)
+	candidates := []string{base, base + "_" + cc}
+	if rec.admin1Code != "" {
+		candidates = append(candidates, base+"_"+cc+"_"+legacyID(rec.admin1Code))
+	}
+	candidates = append(candidates, base+"_"+rec.id)
+
+	for _, id := range candidates {
+		if _, taken := cities[id]; !taken {
+			return id
+		}
+	}
+	// Rows without a geonameid that still collide: number them
+	for n := 2; ; n++ {
+		id := fmt.Sprintf("%s_%d", base, n)
+		if _, taken := cities[id]; !taken {
+			return id
+		}
+	}
+}
+
+// importAliases picks useful alternate names for a city: its ASCII spelling
+// plus GeoNames' alternate names, minus codes, URLs and non-Latin scripts
+// (which the Latin-only search can't match anyway).
+func importAliases(rec geoRecord, max int) []string {
+	seen := map[string]bool{NormalizeName(rec.name): true}
+	var aliases []string
+	for _, alt := range append([]string{rec.asciiName}, rec.alternateNames...) {
+		alt = strings.TrimSpace(alt)
+		key := NormalizeName(alt)
+		if len(aliases) >= max {
+			break
+		}
+		if key == "" || seen[key] || !usableAlias(alt) {
+			continue
+		}
+		seen[key] = true
+		aliases = append(aliases, alt)
+	}
+	return aliases
+}
+
+// usableAlias filters out airport/postal codes, URLs and non-Latin names.
+func usableAlias(s string) bool {
+	if len([]rune(s)) < 3 || strings.Contains(s, "://") {
+		return false
+	}
+	// "MAA", "BOM": IATA codes are all caps and short
+	if len(s) <= 4 && strings.ToUpper(s) == s {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			return false
+		case unicode.IsLetter(r) && !unicode.Is(unicode.Latin, r):
+			return false
+		}
+	}
+	return true
+}
+
+// readGeoNamesTSV parses the GeoNames dump format. Lines starting with '#'
+// are comments; short or malformed lines are an error so a truncated
+// download is noticed.
+func readGeoNamesTSV(r io.Reader) ([]geoRecord, error) {
+	var records []geoRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024) // alternatenames can be long
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		f := strings.Split(text, "\t")
+		if len(f) < 18 {
+			return nil, fmt.Errorf("line %d: expected 19 tab-separated columns, got %d", line, len(f))
+		}
+		rec, err := newGeoRecord(map[string]string{
+			"geonameid":      f[0],
+			"name":           f[1],
+			"asciiname":      f[2],
+			"alternatenames": f[3],
+			"latitude":       f[4],
+			"longitude":      f[5],
+			"feature_class":  f[6],
+			"feature_code":   f[7],
+			"country_code":   f[8],
+			"admin1_code":    f[10],
+			"population":     f[14],
+			"elevation":      f[15],
+			"dem":            f[16],
+			"timezone":       f[17],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// csvColumns maps normalized CSV header names to GeoNames column names.
+var csvColumns = map[string]string{
+	"geonameid":               "geonameid",
+	"geoname_id":              "geonameid",
+	"id":                      "geonameid",
+	"name":                    "name",
+	"asciiname":               "asciiname",
+	"ascii_name":              "asciiname",
+	"alternatenames":          "alternatenames",
+	"alternate_names":         "alternatenames",
+	"latitude":                "latitude",
+	"lat":                     "latitude",
+	"longitude":               "longitude",
+	"lon":                     "longitude",
+	"coordinates":             "coordinates",
+	"feature_class":           "feature_class",
+	"feature_code":            "feature_code",
+	"country_code":            "country_code",
+	"admin1_code":             "admin1_code",
+	"population":              "population",
+	"elevation":               "elevation",
+	"dem":                     "dem",
+	"digital_elevation_model": "dem",
+	"timezone":                "timezone",
+}
+
+// readGeoNamesCSV parses a CSV export with a header row. The delimiter
+// (',' or ';') is taken from the header line.
+func readGeoNamesCSV(r io.Reader) ([]geoRecord, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	firstLine, _, _ := strings.Cut(string(header), "\n")
+
+	cr := csv.NewReader(br)
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+
+	head, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make([]string, len(head))
+	for i, h := range head {
+		columns[i] = csvColumns[csvHeaderName(h)]
+	}
+
+	var records []geoRecord
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make(map[string]string)
+		for i, v := range row {
+			if i < len(columns) && columns[i] != "" {
+				fields[columns[i]] = v
+			}
+		}
+		// Some exports have a single "lat, lon" coordinates column
+		if c, ok := fields["coordinates"]; ok && fields["latitude"] == "" {
+			fields["latitude"], fields["longitude"], _ = strings.Cut(c, ",")
+		}
+
+		line, _ := cr.FieldPos(0)
+		rec, err := newGeoRecord(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// csvHeaderName normalizes a header: "ASCII Name" -> "ascii_name".
+func csvHeaderName(h string) string {
+	h = strings.TrimPrefix(h, "\ufeff") // BOM from spreadsheet exports
+	return strings.Join(strings.FieldsFunc(strings.ToLower(h), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "_")
+}
+
+// newGeoRecord parses and validates one row's named fields.
+func newGeoRecord(f map[string]string) (geoRecord, error) {
+	rec := geoRecord{
+		id:           strings.TrimSpace(f["geonameid"]),
+		name:         strings.TrimSpace(f["name"]),
+		asciiName:    strings.TrimSpace(f["asciiname"]),
+		featureClass: strings.TrimSpace(f["feature_class"]),
+		featureCode:  strings.TrimSpace(f["feature_code"]),
+		country:      strings.TrimSpace(f["country_code"]),
+		admin1Code:   strings.TrimSpace(f["admin1_code"]),
+		timezone:     strings.TrimSpace(f["timezone"]),
+	}
+	if rec.name == "" {
+		return rec, fmt.Errorf("missing name")
+	}
+	if alt := strings.TrimSpace(f["alternatenames"]); alt != "" {
+		rec.alternateNames = strings.Split(alt, ",")
+	}
+
+	var err error
+	if rec.lat, err = strconv.ParseFloat(strings.TrimSpace(f["latitude"]), 64); err != nil || math.Abs(rec.lat) > 90 {
+		return rec, fmt.Errorf("invalid latitude %q for %s", f["latitude"], rec.name)
+	}
+	if rec.lon, err = strconv.ParseFloat(strings.TrimSpace(f["longitude"]), 64); err != nil || math.Abs(rec.lon) > 180 {
+		return rec, fmt.Errorf("invalid longitude %q for %s", f["longitude"], rec.name)
+	}
+	if p := strings.TrimSpace(f["population"]); p != "" {
+		if rec.population, err = strconv.Atoi(p); err != nil {
+			return rec, fmt.Errorf("invalid population %q for %s", p, rec.name)
+		}
+	}
+
+	// Prefer the surveyed elevation; fall back to the DEM (-9999 means no data)
+	if e, err := strconv.ParseFloat(strings.TrimSpace(f["elevation"]), 64); err == nil {
+		rec.elevation = e
+	} else if d, err := strconv.ParseFloat(strings.TrimSpace(f["dem"]), 64); err == nil && d != -9999 {
+		rec.elevation = d
+	}
+	return rec, nil
+}
+
+// ReadAdmin1Codes reads GeoNames' admin1CodesASCII.txt
+// ("IN.25<TAB>Tamil Nadu<TAB>Tamil Nadu<TAB>1255053") into a map for
+// ImportOptions.Admin1Names.
+func ReadAdmin1Codes(r io.Reader) (map[string]string, error) {
+	names := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		f := strings.Split(scanner.Text(), "\t")
+		if len(f) < 2 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		names[strings.ToUpper(f[0])] = f[1]
+	}
+	return names, scanner.Err()
+}
+
+// WriteGazetteer writes cities as a current-version gazetteer file.
+// Cities are written sorted by ID, so re-running an import gives a stable diff.
+func WriteGazetteer(w io.Writer, cities map[string]Location) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(Gazetteer{Version: SchemaVersion, Cities: cities})
+}
+
+func upperSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			set[strings.ToUpper(v)] = true
+		}
+	}
+	return set
+}
+
+func firstNonBlank(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,364 @@
+package locations
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// importFile runs ImportGeoNames on a file under testdata.
+func importFile(t *testing.T, name string, opts ImportOptions) (map[string]Location, ImportStats) {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cities, stats, err := ImportGeoNames(f, opts)
+	if err != nil {
+		t.Fatalf("ImportGeoNames(%s): %v", name, err)
+	}
+	return cities, stats
+}
+
+// admin1Sample reads testdata/admin1_sample.txt.
+func admin1Sample(t *testing.T) map[string]string {
+	t.Helper()
+	f, err := os.Open("testdata/admin1_sample.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	names, err := ReadAdmin1Codes(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+// tsvRow builds one 19-column GeoNames dump line.
+func tsvRow(id, name, alternateNames string, lat, lon float64, featureClass, featureCode, country, admin1 string, population int) string {
+	f := make([]string, 19)
+	f[0], f[1], f[2], f[3] = id, name, name, alternateNames
+	f[4] = strconv.FormatFloat(lat, 'f', -1, 64)
+	f[5] = strconv.FormatFloat(lon, 'f', -1, 64)
+	f[6], f[7], f[8], f[10] = featureClass, featureCode, country, admin1
+	f[14] = strconv.Itoa(population)
+	f[17] = "UTC"
+	return strings.Join(f, "\t")
+}
+
+func sortedIDs(cities map[string]Location) []string {
+	ids := make([]string, 0, len(cities))
+	for id := range cities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestImportGeoNamesTSV(t *testing.T) {
+	cities, stats := importFile(t, "cities_sample.txt", ImportOptions{
+		Format:      FormatTSV,
+		Admin1Names: admin1Sample(t),
+	})
+
+	want := []string{
+		"bengaluru", "chennai", "delhi", "fatehpur_sikri", "hyderabad", "hyderabad_pk",
+		"london", "london_ca", "madurai", "mumbai", "mumbai_suburban", "paris", "pune", "sao_paulo",
+	}
+	if got := sortedIDs(cities); !reflect.DeepEqual(got, want) {
+		t.Errorf("IDs = %v, want %v", got, want)
+	}
+	// Varkala Beach is feature class L; the second Chennai is within 25 km of
+	// the bigger one (Mumbai Suburban is as close to Mumbai, but named differently)
+	if stats.Read != 16 || stats.Filtered != 1 || stats.Duplicates != 1 || stats.Imported != 14 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	chennai := cities["chennai"]
+	if chennai.Country != "IN" || chennai.Admin1 != "Tamil Nadu" || chennai.Timezone != "Asia/Kolkata" ||
+		chennai.Latitude != 13.08784 || chennai.Longitude != 80.27847 || chennai.Population != 4646732 {
+		t.Errorf("chennai = %+v", chennai)
+	}
+	// Elevation falls back to the DEM column when the surveyed one is empty
+	if chennai.Elevation != 9 {
+		t.Errorf("chennai elevation = %g, want 9", chennai.Elevation)
+	}
+	if got := cities["sao_paulo"].Name; got != "São Paulo" {
+		t.Errorf("sao_paulo name = %q, want the non-ASCII display name", got)
+	}
+	if got := cities["hyderabad_pk"].Admin1; got != "Sindh" {
+		t.Errorf("hyderabad_pk admin1 = %q, want Sindh", got)
+	}
+}
+
+func TestImportGeoNamesCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			// The testdata file is ';'-separated with a single Coordinates column
+			name: "semicolon with coordinates column",
+		},
+		{
+			name: "comma with lat/lon columns",
+			data: "geonameid,name,asciiname,alternatenames,latitude,longitude,feature class,feature code,country code,admin1 code,population,elevation,dem,timezone\n" +
+				`1264527,Chennai,Chennai,"MAA,Madras,Chenai",13.08784,80.27847,P,PPLA,IN,25,4646732,,9,Asia/Kolkata` + "\n" +
+				`1277333,Bengaluru,Bengaluru,"BLR,Bangalore,Bengalooru",12.97194,77.59369,P,PPLA,IN,19,8443675,,920,Asia/Kolkata` + "\n" +
+				`2643743,London,London,"LON,Londres,Londra",51.50853,-0.12574,P,PPLC,GB,ENG,8961989,,25,Europe/London` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ImportOptions{Format: FormatCSV, Admin1Names: admin1Sample(t)}
+			var cities map[string]Location
+			if tt.data == "" {
+				cities, _ = importFile(t, "cities_sample.csv", opts)
+			} else {
+				var err error
+				if cities, _, err = ImportGeoNames(strings.NewReader(tt.data), opts); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got, want := sortedIDs(cities), []string{"bengaluru", "chennai", "london"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("IDs = %v, want %v", got, want)
+			}
+			london := cities["london"]
+			if london.Latitude != 51.50853 || london.Longitude != -0.12574 {
+				t.Errorf("london at %g, %g, want 51.50853, -0.12574", london.Latitude, london.Longitude)
+			}
+			if london.Country != "GB" || london.Admin1 != "England" || london.Population != 8961989 || london.Elevation != 25 {
+				t.Errorf("london = %+v", london)
+			}
+			if got, want := cities["chennai"].Aliases, []string{"Madras", "Chenai"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("chennai aliases = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestImportGeoNamesFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ImportOptions
+		want []string
+	}{
+		{
+			name: "countries",
+			opts: ImportOptions{Countries: []string{"gb", "CA"}},
+			want: []string{"london", "london_ca"},
+		},
+		{
+			name: "min population",
+			opts: ImportOptions{MinPopulation: 8_000_000},
+			want: []string{"bengaluru", "delhi", "london", "mumbai", "sao_paulo"},
+		},
+		{
+			name: "feature codes",
+			opts: ImportOptions{FeatureCodes: []string{"pplc"}},
+			want: []string{"london", "paris"},
+		},
+		{
+			// An explicit feature code list can pull in non-P places
+			name: "non-populated feature code",
+			opts: ImportOptions{FeatureCodes: []string{"BCH"}},
+			want: []string{"varkala_beach"},
+		},
+		{
+			name: "combined",
+			opts: ImportOptions{Countries: []string{"IN"}, MinPopulation: 1_000_000, FeatureCodes: []string{"PPLA2"}},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cities, stats := importFile(t, "cities_sample.txt", tt.opts)
+			got := sortedIDs(cities)
+			if len(got) == 0 {
+				got = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IDs = %v, want %v", got, tt.want)
+			}
+			if stats.Read != stats.Filtered+stats.Duplicates+stats.Imported {
+				t.Errorf("stats don't add up: %+v", stats)
+			}
+		})
+	}
+}
+
+func TestImportGeoNamesDuplicates(t *testing.T) {
+	data := strings.Join([]string{
+		tsvRow("1", "Springfield", "", 39.80, -89.64, "P", "PPLA", "US", "IL", 114000),
+		// ~11 km away in the same country: a duplicate of the bigger one
+		tsvRow("2", "Springfield", "", 39.90, -89.64, "P", "PPL", "US", "IL", 5000),
+		// Same spot, other country: not a duplicate
+		tsvRow("3", "Springfield", "", 39.80, -89.64, "P", "PPL", "CA", "ON", 3000),
+		// ~30 km away: far enough to be a different place
+		tsvRow("4", "Springfield", "", 40.07, -89.64, "P", "PPL", "US", "IL", 2000),
+	}, "\n")
+
+	cities, stats, err := ImportGeoNames(strings.NewReader(data), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Duplicates != 1 || stats.Imported != 3 {
+		t.Errorf("stats = %+v, want 1 duplicate and 3 imported", stats)
+	}
+	for _, loc := range cities {
+		if loc.Population == 5000 {
+			t.Errorf("the smaller Springfield within %d km was kept", duplicateRadiusKm)
+		}
+	}
+}
+
+func TestImportGeoNamesIDs(t *testing.T) {
+	// Springfields in one country: the most populous gets the plain ID, then
+	// base_cc, base_cc_admin1 and base_<geonameid> as each is taken
+	data := strings.Join([]string{
+		tsvRow("101", "Springfield", "", 39.80, -89.64, "P", "PPLA", "US", "IL", 114000),
+		tsvRow("102", "Springfield", "", 37.21, -93.29, "P", "PPL", "US", "MO", 169000),
+		tsvRow("103", "Springfield", "", 42.10, -72.59, "P", "PPL", "US", "MA", 155000),
+		tsvRow("104", "Springfield", "", 44.05, -123.02, "P", "PPL", "US", "OR", 62000),
+		tsvRow("105", "Springfield", "", 44.00, -123.00, "P", "PPL", "US", "OR", 90000),
+		tsvRow("106", "Springfield", "", 45.00, -123.00, "P", "PPL", "US", "OR", 10000),
+	}, "\n")
+
+	cities, _, err := ImportGeoNames(strings.NewReader(data), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{
+		"springfield":       169000, // MO
+		"springfield_us":    155000, // MA
+		"springfield_us_il": 114000,
+		"springfield_us_or": 90000,
+		"springfield_106":   10000, // its base_cc_admin1 is taken by the other Oregon one
+	}
+	// Record 104 is within 25 km of 105, so it is dropped as a duplicate
+	if len(cities) != len(want) {
+		t.Errorf("IDs = %v, want %d cities", sortedIDs(cities), len(want))
+	}
+	for id, population := range want {
+		if got := cities[id].Population; got != population {
+			t.Errorf("%s population = %d, want %d", id, got, population)
+		}
+	}
+}
+
+func TestImportGeoNamesAliases(t *testing.T) {
+	tests := []struct {
+		name           string
+		alternateNames string
+		maxAliases     int
+		want           []string
+	}{
+		{
+			name:           "IATA codes, non-Latin scripts and the display name dropped",
+			alternateNames: "MAA,Madras,Čennaj,Ченнай,சென்னை,chennai,MADRAS",
+			want:           []string{"Madras", "Čennaj"},
+		},
+		{
+			name:           "URLs and names with digits dropped",
+			alternateNames: "https://en.wikipedia.org/wiki/Chennai,Madras,600001,Chennai 1",
+			want:           []string{"Madras"},
+		},
+		{
+			name:           "short names dropped, longer all-caps kept",
+			alternateNames: "Ma,CHENNAPATNAM",
+			want:           []string{"CHENNAPATNAM"},
+		},
+		{
+			name:           "capped",
+			alternateNames: "Madras,Madrasa,Chenai,Chennapatnam",
+			maxAliases:     2,
+			want:           []string{"Madras", "Madrasa"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tsvRow("1264527", "Chennai", tt.alternateNames, 13.08784, 80.27847, "P", "PPLA", "IN", "25", 4646732)
+			cities, _, err := ImportGeoNames(strings.NewReader(data), ImportOptions{MaxAliases: tt.maxAliases})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cities["chennai"].Aliases; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("aliases = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportGeoNamesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ImportOptions
+		data string
+	}{
+		{"unknown format", ImportOptions{Format: "xml"}, ""},
+		{"truncated TSV line", ImportOptions{}, "1264527\tChennai\tChennai\t\t13.08784\t80.27847"},
+		{"latitude out of range", ImportOptions{}, tsvRow("1", "Nowhere", "", 91, 0, "P", "PPL", "XX", "", 1)},
+		{"CSV without name", ImportOptions{Format: FormatCSV}, "name,latitude,longitude\n,1,2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ImportGeoNames(strings.NewReader(tt.data), tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestWriteGazetteerRoundTrip(t *testing.T) {
+	cities, _ := importFile(t, "cities_sample.txt", ImportOptions{Admin1Names: admin1Sample(t)})
+
+	var buf bytes.Buffer
+	if err := WriteGazetteer(&buf, cities); err != nil {
+		t.Fatal(err)
+	}
+	g, err := ParseGazetteer(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseGazetteer: %v\n%s", err, buf.Bytes())
+	}
+
+	if g.Version != SchemaVersion {
+		t.Errorf("version = %d, want %d", g.Version, SchemaVersion)
+	}
+	if !reflect.DeepEqual(g.Cities, cities) {
+		t.Errorf("cities changed in the round trip:\n got %+v\nwant %+v", g.Cities, cities)
+	}
+
+	// Writing is deterministic, so re-imports diff cleanly
+	var again bytes.Buffer
+	if err := WriteGazetteer(&again, g.Cities); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("writing the same cities twice gave different output")
+	}
+
+	// Lookups work on the parsed file, and the bigger Hyderabad keeps the name
+	for name, want := range map[string]string{
+		"Madras":       "chennai",
+		"bombay":       "mumbai",
+		"Hyderabad":    "hyderabad",
+		"hyderabad pk": "hyderabad_pk",
+		"Sao Paulo":    "sao_paulo",
+		"São Paulo":    "sao_paulo",
+		"London":       "london",
+	} {
+		if id, _, ok := g.Lookup(name); !ok || id != want {
+			t.Errorf("Lookup(%q) = %q, %v, want %q", name, id, ok, want)
+		}
+	}
+}
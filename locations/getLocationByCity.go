@@ -1,39 +1,68 @@
 package locations
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"strings"
+	"errors"
+	"sync"
 )
 
-var CITIES_FILE_PATH = "./locations/cities.json"
+// CITIES_FILE_PATH overrides the built-in city list with a gazetteer file.
+// Empty uses the cities.json embedded in the binary.
+var CITIES_FILE_PATH = ""
 
-func GetLocationByCity(city string) (Location, error) {
-	citiesFileData, citiesFileError := os.ReadFile(CITIES_FILE_PATH)
+// ErrCityNotFound is returned by GetLocationByCity for unknown cities.
+var ErrCityNotFound = errors.New("city not found in our Database")
+
+// defaultStore caches the gazetteer at CITIES_FILE_PATH, so repeated lookups
+// (a lookup, then suggestions, then --near) parse the file once.
+var defaultStore struct {
+	sync.Mutex
+	path  string
+	store *Store
+}
+
+// loadDefault returns the gazetteer at CITIES_FILE_PATH, loading it on first use.
+func loadDefault() (*Gazetteer, error) {
+	defaultStore.Lock()
+	defer defaultStore.Unlock()
+
+	if defaultStore.store == nil || defaultStore.path != CITIES_FILE_PATH {
+		store, loadError := NewStore(CITIES_FILE_PATH)
+
+		if loadError != nil {
+			return nil, loadError
+		}
 
-	if citiesFileError != nil {
-		return Location{}, citiesFileError
+		defaultStore.path = CITIES_FILE_PATH
+		defaultStore.store = store
 	}
 
-	var citiesData map[string]Location
+	return defaultStore.store.Current().Gazetteer, nil
+}
 
-	// Unmarshall the file data
-	unmarshalError := json.Unmarshal(citiesFileData, &citiesData)
+// LoadCities returns every city in CITIES_FILE_PATH, keyed by snake_case ID.
+func LoadCities() (map[string]Location, error) {
+	gazetteer, loadError := loadDefault()
 
-	if unmarshalError != nil {
-		return Location{}, unmarshalError
+	if loadError != nil {
+		return nil, loadError
 	}
 
-	city = strings.ToLower(city)
-	city = strings.Trim(city, " ")
-	city = strings.ReplaceAll(city, " ", "_")
+	return gazetteer.Cities, nil
+}
+
+// GetLocationByCity finds a city by name or alias ("Bombay" finds Mumbai).
+func GetLocationByCity(city string) (Location, error) {
+	gazetteer, loadError := loadDefault()
+
+	if loadError != nil {
+		return Location{}, loadError
+	}
 
-	cityLocation, ok := citiesData[city]
+	_, cityLocation, ok := gazetteer.Lookup(city)
 
 	if ok {
 		return cityLocation, nil
 	}
 
-	return Location{}, fmt.Errorf("city not found in our Database")
+	return Location{}, ErrCityNotFound
 }
@@ -0,0 +1,193 @@
+package locations
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// EarthRadiusKm is the mean Earth radius used for great-circle distances.
+const EarthRadiusKm = 6371.0
+
+// Nearby is a city returned by Index.Nearest, with its distance from the query point.
+type Nearby struct {
+	ID string `json:"id"`
+	Location
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// Index is a spatial index over the gazetteer for nearest-city queries.
+//
+// Cities are stored as points on the unit sphere in a 3-d tree. Straight-line
+// (chord) distance between unit vectors grows with great-circle distance, so
+// the nearest points in 3-d are the nearest on the globe - with no special
+// cases at the poles or the antimeridian.
+type Index struct {
+	points []indexPoint
+	root   *kdNode
+}
+
+// indexPoint is one city projected onto the unit sphere.
+type indexPoint struct {
+	name     string
+	location Location
+	xyz      [3]float64
+}
+
+// kdNode splits its subtree on one axis (0=x, 1=y, 2=z) at points[point].
+type kdNode struct {
+	point       int
+	axis        int
+	left, right *kdNode
+}
+
+// NewIndex builds an index over a name -> Location map.
+func NewIndex(cities map[string]Location) *Index {
+	ix := &Index{points: make([]indexPoint, 0, len(cities))}
+	for name, loc := range cities {
+		ix.points = append(ix.points, indexPoint{name: name, location: loc, xyz: toUnitVector(loc.Latitude, loc.Longitude)})
+	}
+	// Sort by name so the tree (and tie-breaking) doesn't depend on map order
+	sort.Slice(ix.points, func(i, j int) bool { return ix.points[i].name < ix.points[j].name })
+
+	order := make([]int, len(ix.points))
+	for i := range order {
+		order[i] = i
+	}
+	ix.root = ix.build(order, 0)
+	return ix
+}
+
+// Len returns the number of indexed cities.
+func (ix *Index) Len() int {
+	return len(ix.points)
+}
+
+// build creates a balanced subtree from the given point indexes.
+func (ix *Index) build(order []int, depth int) *kdNode {
+	if len(order) == 0 {
+		return nil
+	}
+	axis := depth % 3
+	sort.Slice(order, func(i, j int) bool { return ix.points[order[i]].xyz[axis] < ix.points[order[j]].xyz[axis] })
+
+	mid := len(order) / 2
+	return &kdNode{
+		point: order[mid],
+		axis:  axis,
+		left:  ix.build(order[:mid], depth+1),
+		right: ix.build(order[mid+1:], depth+1),
+	}
+}
+
+// Nearest returns up to n cities closest to lat/lon, nearest first.
+func (ix *Index) Nearest(lat, lon float64, n int) []Nearby {
+	if n <= 0 || ix.root == nil {
+		return nil
+	}
+
+	q := toUnitVector(lat, lon)
+	best := &candidateHeap{}
+	ix.search(ix.root, q, n, best)
+
+	// The heap pops farthest first; fill the result from the back
+	out := make([]Nearby, best.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		c := heap.Pop(best).(candidate)
+		p := ix.points[c.point]
+		out[i] = Nearby{
+			ID:         p.name,
+			Location:   p.location,
+			DistanceKm: chordToKm(math.Sqrt(c.dist2)),
+		}
+	}
+	return out
+}
+
+// search walks the tree, keeping the n closest points seen in best.
+func (ix *Index) search(node *kdNode, q [3]float64, n int, best *candidateHeap) {
+	if node == nil {
+		return
+	}
+
+	p := ix.points[node.point]
+	d2 := squaredDistance(p.xyz, q)
+	if best.Len() < n {
+		heap.Push(best, candidate{point: node.point, dist2: d2})
+	} else if c := (candidate{point: node.point, dist2: d2}); c.closerThan((*best)[0]) {
+		(*best)[0] = c
+		heap.Fix(best, 0)
+	}
+
+	// Visit the side containing q first, then the other side only if the
+	// splitting plane is closer than the current n-th best
+	diff := q[node.axis] - p.xyz[node.axis]
+	near, far := node.left, node.right
+	if diff > 0 {
+		near, far = node.right, node.left
+	}
+	ix.search(near, q, n, best)
+	if best.Len() < n || diff*diff <= (*best)[0].dist2 {
+		ix.search(far, q, n, best)
+	}
+}
+
+// candidate is a point and its squared chord distance to the query.
+type candidate struct {
+	point int
+	dist2 float64
+}
+
+// closerThan orders candidates by distance, then by name (points are sorted
+// by name, so by index), so equally distant cities come back in name order.
+func (c candidate) closerThan(o candidate) bool {
+	if c.dist2 != o.dist2 {
+		return c.dist2 < o.dist2
+	}
+	return c.point < o.point
+}
+
+// candidateHeap is a max-heap on distance, so the worst candidate is on top.
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int           { return len(h) }
+func (h candidateHeap) Less(i, j int) bool { return h[j].closerThan(h[i]) }
+func (h candidateHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *candidateHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// toUnitVector projects a lat/lon (degrees) onto the unit sphere.
+func toUnitVector(lat, lon float64) [3]float64 {
+	latR := lat * math.Pi / 180
+	lonR := lon * math.Pi / 180
+	return [3]float64{
+		math.Cos(latR) * math.Cos(lonR),
+		math.Cos(latR) * math.Sin(lonR),
+		math.Sin(latR),
+	}
+}
+
+func squaredDistance(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// chordToKm converts a chord length on the unit sphere to a great-circle distance.
+func chordToKm(chord float64) float64 {
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, chord/2))
+}
+
+// DistanceKm is the great-circle (haversine) distance between two coordinates in km.
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,135 @@
+package locations
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+// bruteNearest is Index.Nearest the slow way: every city, sorted by distance
+// then ID.
+func bruteNearest(cities map[string]Location, lat, lon float64, n int) []string {
+	q := toUnitVector(lat, lon)
+	ids := make([]string, 0, len(cities))
+	dist := make(map[string]float64, len(cities))
+	for id, loc := range cities {
+		ids = append(ids, id)
+		dist[id] = squaredDistance(toUnitVector(loc.Latitude, loc.Longitude), q)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if di, dj := dist[ids[i]], dist[ids[j]]; di != dj {
+			return di < dj
+		}
+		return ids[i] < ids[j]
+	})
+	return ids[:min(n, len(ids))]
+}
+
+func TestIndexNearestMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	randomPoint := func() (float64, float64) {
+		// Uniform on the sphere, so the poles aren't overcrowded
+		return math.Asin(2*rng.Float64()-1) * 180 / math.Pi, rng.Float64()*360 - 180
+	}
+
+	cities := make(map[string]Location)
+	for i := range 2000 {
+		lat, lon := randomPoint()
+		cities[fmt.Sprintf("city_%04d", i)] = Location{Latitude: lat, Longitude: lon}
+	}
+	// Same-spot cities must come back in ID order
+	for _, id := range []string{"twin_b", "twin_a", "twin_c"} {
+		cities[id] = Location{Latitude: 10, Longitude: 20}
+	}
+	ix := NewIndex(cities)
+	if ix.Len() != len(cities) {
+		t.Fatalf("Len() = %d, want %d", ix.Len(), len(cities))
+	}
+
+	queries := [][2]float64{{10, 20}, {90, 0}, {-90, 0}, {0, 180}, {0, -180}}
+	for range 200 {
+		lat, lon := randomPoint()
+		queries = append(queries, [2]float64{lat, lon})
+	}
+	for _, q := range queries {
+		for _, n := range []int{1, 3, 10} {
+			got := ix.Nearest(q[0], q[1], n)
+			want := bruteNearest(cities, q[0], q[1], n)
+			if len(got) != len(want) {
+				t.Fatalf("Nearest(%g, %g, %d) returned %d cities, want %d", q[0], q[1], n, len(got), len(want))
+			}
+			for i := range got {
+				if got[i].ID != want[i] {
+					t.Fatalf("Nearest(%g, %g, %d)[%d] = %s, want %s", q[0], q[1], n, i, got[i].ID, want[i])
+				}
+				loc := cities[want[i]]
+				if d := DistanceKm(q[0], q[1], loc.Latitude, loc.Longitude); math.Abs(got[i].DistanceKm-d) > 1e-6 {
+					t.Errorf("%s is %g km from (%g, %g), want %g", want[i], got[i].DistanceKm, q[0], q[1], d)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexNearest(t *testing.T) {
+	cities := map[string]Location{
+		"chennai":   {Latitude: 13.0827, Longitude: 80.2707},
+		"mumbai":    {Latitude: 19.0760, Longitude: 72.8777},
+		"suva":      {Latitude: -18.1416, Longitude: 178.4419},
+		"apia":      {Latitude: -13.8333, Longitude: -171.7667},
+		"reykjavik": {Latitude: 64.1466, Longitude: -21.9426},
+	}
+	ix := NewIndex(cities)
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		n        int
+		want     []string
+	}{
+		{"nearest first", 12.9716, 77.5946, 2, []string{"chennai", "mumbai"}},
+		// Just east of the antimeridian, Suva (west of it) is still closest
+		{"across the antimeridian", -17, -179.5, 1, []string{"suva"}},
+		{"near the pole", 89, 150, 1, []string{"reykjavik"}},
+		{"more than there are", 0, 0, 10, []string{"reykjavik", "mumbai", "chennai", "suva", "apia"}},
+		{"none asked", 0, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range ix.Nearest(tt.lat, tt.lon, tt.n) {
+				got = append(got, c.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Nearest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := NewIndex(nil).Nearest(0, 0, 1); got != nil {
+		t.Errorf("empty index returned %v", got)
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 13.0827, 80.2707, 13.0827, 80.2707, 0},
+		{"quarter meridian", 0, 0, 90, 0, math.Pi / 2 * EarthRadiusKm},
+		{"antipodes", 0, 0, 0, 180, math.Pi * EarthRadiusKm},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, math.Pi / 180 * EarthRadiusKm},
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 343.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DistanceKm(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("DistanceKm = %.1f, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package locations
+
+import (
+	"sort"
+	"strings"
+)
+
+// Match is a city returned by Search, ranked by how well it matches the query.
+type Match struct {
+	ID string `json:"id"`
+	Location
+	Score float64 `json:"score"`
+}
+
+// Scores for each kind of match, best first. Typos score below every
+// kind of exact match, and lose a little more for each edit.
+const (
+	scoreExact     = 1.0
+	scorePrefix    = 0.9
+	scoreToken     = 0.8
+	scoreSubstring = 0.7
+	scoreTypo      = 0.6
+	typoPenalty    = 0.1
+)
+
+// Search ranks the cities whose names match query, best first, returning at
+// most limit results. A city's ID, display name, aliases and localized names
+// are all candidates ("bombay" finds Mumbai). Names match on (in order of
+// preference) equality, a prefix, word prefixes ("york" -> "new_york"), a
+// substring, or a small number of typos ("banglore" -> "bangalore").
+// Case, underscores and punctuation are ignored.
+func Search(cities map[string]Location, query string, limit int) []Match {
+	q := NormalizeName(query)
+	if q == "" || limit <= 0 {
+		return nil
+	}
+
+	var matches []Match
+	for id, loc := range cities {
+		if score, ok := cityScore(q, id, loc); ok {
+			matches = append(matches, Match{ID: id, Location: loc, Score: score})
+		}
+	}
+
+	// Best score first; among equals prefer the bigger city, then the shorter name
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Population != b.Population {
+			return a.Population > b.Population
+		}
+		if len(a.ID) != len(b.ID) {
+			return len(a.ID) < len(b.ID)
+		}
+		return a.ID < b.ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// NormalizeName lowercases a city name and turns underscores, hyphens and
+// punctuation into single spaces, so "New_York", "new-york" and " New York "
+// all compare equal.
+func NormalizeName(s string) string {
+	s = strings.ToLower(s)
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', ',', '.', '\'':
+			return ' '
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// cityScore is the best matchScore over a city's ID, display name, aliases
+// and localized names.
+func cityScore(q, id string, loc Location) (float64, bool) {
+	best, found := 0.0, false
+	for _, name := range append([]string{id, loc.Name}, loc.allAliases()...) {
+		if score, ok := matchScore(q, NormalizeName(name)); ok && score > best {
+			best, found = score, true
+		}
+	}
+	return best, found
+}
+
+// matchScore scores a normalized name against a normalized query.
+func matchScore(q, name string) (float64, bool) {
+	switch {
+	case name == q:
+		return scoreExact, true
+	case strings.HasPrefix(name, q):
+		// "chen" is a better match for "chennai" than "c" is
+		return scorePrefix + 0.05*float64(len(q))/float64(len(name)), true
+	case tokensMatch(q, name):
+		return scoreToken, true
+	case strings.Contains(name, q):
+		return scoreSubstring, true
+	}
+
+	maxEdits := allowedEdits(len([]rune(q)))
+	if maxEdits == 0 {
+		return 0, false
+	}
+
+	// Compare against the whole name, and against a same-length prefix so
+	// half-typed names with a typo ("banglo") still find "bangalore"
+	d := editDistance(q, name)
+	if nameRunes := []rune(name); len(nameRunes) > len([]rune(q)) {
+		if pd := editDistance(q, string(nameRunes[:len([]rune(q))])); pd < d {
+			d = pd
+		}
+	}
+	if d > maxEdits {
+		return 0, false
+	}
+	return scoreTypo - typoPenalty*float64(d-1), true
+}
+
+// tokensMatch reports whether every word of q is a prefix of some word of name.
+func tokensMatch(q, name string) bool {
+	nameTokens := strings.Fields(name)
+	for _, qt := range strings.Fields(q) {
+		found := false
+		for _, nt := range nameTokens {
+			if strings.HasPrefix(nt, qt) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// allowedEdits is how many typos a query of n characters may contain.
+// Very short queries must match exactly, or everything would match.
+func allowedEdits(n int) int {
+	switch {
+	case n < 3:
+		return 0
+	case n <= 5:
+		return 1
+	case n <= 9:
+		return 2
+	}
+	return 3
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and adjacent transpositions each cost 1.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Three rolling rows: two back (for transpositions), previous and current
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,133 @@
+package locations
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"chennai", "chennai", 0},
+		{"kitten", "sitting", 3},
+		{"chenai", "chennai", 1},   // insertion
+		{"chennaii", "chennai", 1}, // deletion
+		{"chennoi", "chennai", 1},  // substitution
+		{"chenani", "chennai", 1},  // adjacent transposition
+		{"banglore", "bangalore", 1},
+		{"ca", "ac", 1},
+		// Optimal string alignment can't edit a transposed pair again, so this
+		// is 3, not the unrestricted Damerau-Levenshtein 2
+		{"ca", "abc", 3},
+		{"münchen", "munchen", 1}, // runes, not bytes
+		{"तमिल", "तमल", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d (not symmetric)", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	tests := []struct {
+		q, name string
+		want    float64 // 0 for no match
+	}{
+		{"mumbai", "mumbai", scoreExact},
+		{"new york", "new york", scoreExact},
+		{"york", "new york", scoreToken},
+		{"ne yo", "new york", scoreToken},
+		{"umba", "mumbai", scoreSubstring},
+		{"mumbia", "mumbai", scoreTypo},
+		{"mumbaii", "mumbai", scoreTypo},
+		{"banglore", "bangalore", scoreTypo},
+		{"bnaglore", "bangalore", scoreTypo - typoPenalty},
+		// Half-typed with a typo: compared against the name's same-length prefix
+		{"bangla", "bangalore", scoreTypo},
+		// Short queries get no typos; medium ones only one
+		{"mu", "ma", 0},
+		{"mubmia", "mumbai", scoreTypo - typoPenalty},
+		{"pnue", "pune", scoreTypo},
+		{"pnuee", "pune", 0},
+		{"delhi", "mumbai", 0},
+	}
+	for _, tt := range tests {
+		got, ok := matchScore(tt.q, tt.name)
+		if ok != (tt.want != 0) || fmt.Sprintf("%.4f", got) != fmt.Sprintf("%.4f", tt.want) {
+			t.Errorf("matchScore(%q, %q) = %.4f, %v, want %.4f", tt.q, tt.name, got, ok, tt.want)
+		}
+	}
+
+	// A longer prefix is a better match
+	short, _ := matchScore("c", "chennai")
+	long, _ := matchScore("chen", "chennai")
+	if !(scorePrefix < short && short < long && long < scoreExact) {
+		t.Errorf("prefix scores c=%g chen=%g, want scorePrefix < c < chen < scoreExact", short, long)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	cities := map[string]Location{
+		"mumbai":    {Name: "Mumbai", Aliases: []string{"Bombay"}, Population: 12691836},
+		"bengaluru": {Name: "Bengaluru", Aliases: []string{"Bangalore"}, Population: 8443675},
+		"new_york":  {Name: "New York", Population: 8804190},
+		"york":      {Name: "York", Population: 141685},
+		"chennai":   {Name: "Chennai", Names: map[string]string{"ta": "சென்னை"}, Population: 4646732},
+		"london":    {Name: "London", Population: 8961989},
+		"london_ca": {Name: "London", Country: "CA", Population: 346765},
+	}
+
+	tests := []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		{"Bombay", 5, []string{"mumbai"}},
+		{"BANGALORE", 5, []string{"bengaluru"}},
+		{"banglore", 5, []string{"bengaluru"}},
+		{"சென்னை", 5, []string{"chennai"}},
+		// Exact match beats the word prefix in "new york"
+		{"york", 5, []string{"york", "new_york"}},
+		// Equal scores: the bigger city first
+		{"london", 5, []string{"london", "london_ca"}},
+		{"london", 1, []string{"london"}},
+		{"new-york", 5, []string{"new_york"}},
+		{"xyzzy", 5, nil},
+		{"  ", 5, nil},
+		{"mumbai", 0, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range Search(cities, tt.query, tt.limit) {
+			got = append(got, m.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Search(%q, %d) = %v, want %v", tt.query, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	for in, want := range map[string]string{
+		"New_York":   "new york",
+		" new-york ": "new york",
+		"St. John's": "st john s",
+		"Mumbai, IN": "mumbai in",
+		"São  Paulo": "são paulo",
+		"":           "",
+		"__":         "",
+	} {
+		if got := NormalizeName(in); got != want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package locations
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCities is cities.json, built into every binary that imports this
+// package so the gazetteer works from any working directory.
+//
+//go:embed cities.json
+var defaultCities []byte
+
+// EmbeddedPath is Snapshot.Path for the built-in gazetteer.
+const EmbeddedPath = "(embedded)"
+
+// Snapshot is one immutable, fully loaded version of the gazetteer.
+// Readers keep using the snapshot they got even while a reload swaps in a
+// new one, so a lookup never sees a half-loaded city list.
+type Snapshot struct {
+	Gazetteer *Gazetteer
+	Index     *Index
+	// Path is the file this snapshot was loaded from.
+	Path string
+	// LoadedAt is when the snapshot was parsed.
+	LoadedAt time.Time
+}
+
+// Store holds the current gazetteer snapshot for a file and reloads it on
+// demand. A reload that fails (missing file, bad JSON, invalid coordinates)
+// keeps the last good snapshot in place.
+type Store struct {
+	path    string
+	current atomic.Pointer[Snapshot]
+
+	mu       sync.Mutex // serializes reloads
+	lastSeen fileVersion
+}
+
+// fileVersion identifies the file contents a reload last attempted.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewStore loads the gazetteer at path, or the embedded default cities.json
+// when path is empty. Unlike later reloads, the initial load has no previous
+// snapshot to fall back on, so any error is returned.
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Current returns the latest good snapshot.
+func (s *Store) Current() *Snapshot {
+	return s.current.Load()
+}
+
+// Path returns the file the store (re)loads from, or EmbeddedPath.
+func (s *Store) Path() string {
+	if s.path == "" {
+		return EmbeddedPath
+	}
+	return s.path
+}
+
+// Reload re-reads the file and swaps in the new snapshot if it parses.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reloadLocked()
+}
+
+// ReloadIfChanged reloads only if the file's size or modification time
+// differs from the last attempt. A malformed file is therefore reported
+// once, not on every poll, until it is edited again.
+func (s *Store) ReloadIfChanged() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		// The embedded gazetteer never changes
+		return false, nil
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("gazetteer: %w", err)
+	}
+	if (fileVersion{modTime: info.ModTime(), size: info.Size()}) == s.lastSeen {
+		return false, nil
+	}
+	return true, s.reloadLocked()
+}
+
+// reloadLocked does the reload. Must be called with s.mu held.
+func (s *Store) reloadLocked() error {
+	if s.path == "" {
+		g, err := ParseGazetteer(defaultCities)
+		if err != nil {
+			return fmt.Errorf("embedded cities.json: %w", err)
+		}
+		s.current.Store(newSnapshot(g, EmbeddedPath))
+		return nil
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("gazetteer: %w", err)
+	}
+	s.lastSeen = fileVersion{modTime: info.ModTime(), size: info.Size()}
+
+	g, err := LoadGazetteer(s.path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.current.Store(newSnapshot(g, s.path))
+	return nil
+}
+
+func newSnapshot(g *Gazetteer, path string) *Snapshot {
+	return &Snapshot{
+		Gazetteer: g,
+		Index:     NewIndex(g.Cities),
+		Path:      path,
+		LoadedAt:  time.Now(),
+	}
+}
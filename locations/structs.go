@@ -1,6 +1,49 @@
 package locations
 
+import "sort"
+
+// Location is one city in the gazetteer. Only the coordinates are required;
+// the legacy cities.json format has nothing else.
 type Location struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
+	// Name is the display name, e.g. "New York" for the "new_york" entry.
+	Name string `json:"name,omitempty"`
+	// Names are display names in other languages, keyed by language code,
+	// e.g. {"hi": "मुंबई", "ta": "மும்பை"}. They can be looked up like aliases.
+	Names map[string]string `json:"names,omitempty"`
+	// Aliases are alternate and former names, e.g. "Bombay" for Mumbai.
+	Aliases []string `json:"aliases,omitempty"`
+	// Country is the ISO 3166-1 alpha-2 country code, e.g. "IN".
+	Country string `json:"country,omitempty"`
+	// Admin1 is the first-level region: state, province, etc.
+	Admin1 string `json:"admin1,omitempty"`
+	// Timezone is the IANA time zone, e.g. "Asia/Kolkata".
+	Timezone   string  `json:"timezone,omitempty"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Elevation  float64 `json:"elevation,omitempty"` // meters above sea level
+	Population int     `json:"population,omitempty"`
+}
+
+// DisplayName returns the city's name in the given language, or Name if
+// there is no translation.
+func (l Location) DisplayName(lang string) string {
+	if name, ok := l.Names[lang]; ok && name != "" {
+		return name
+	}
+	return l.Name
+}
+
+// allAliases is Aliases followed by the localized names, in language order.
+func (l Location) allAliases() []string {
+	langs := make([]string, 0, len(l.Names))
+	for lang := range l.Names {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	aliases := append([]string(nil), l.Aliases...)
+	for _, lang := range langs {
+		aliases = append(aliases, l.Names[lang])
+	}
+	return aliases
 }
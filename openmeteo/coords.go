@@ -0,0 +1,55 @@
+package openmeteo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxCoordinateDecimals is the most decimal places accepted for lat/lon.
+// 6 decimals is ~11cm; anything beyond that is noise, not precision.
+const MaxCoordinateDecimals = 6
+
+// ErrInvalidCoordinates is returned for malformed or out-of-range lat/lon.
+var ErrInvalidCoordinates = errors.New("invalid coordinates")
+
+// ParseCoordinates validates lat/lon query values: both must be plain decimal
+// numbers, latitude in [-90, 90], longitude in [-180, 180], with at most
+// MaxCoordinateDecimals decimal places.
+func ParseCoordinates(latStr, lonStr string) (float64, float64, error) {
+	lat, err := parseCoordinate("lat", latStr, 90)
+	if err != nil {
+		return 0, 0, err
+	}
+	lon, err := parseCoordinate("lon", lonStr, 180)
+	if err != nil {
+		return 0, 0, err
+	}
+	return lat, lon, nil
+}
+
+// parseCoordinate parses one coordinate and checks it against ±limit.
+func parseCoordinate(name, s string, limit float64) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("%w: %s is required", ErrInvalidCoordinates, name)
+	}
+
+	// Only accept plain decimals: no exponents, hex, NaN or Inf
+	if strings.ContainsAny(s, "eExXpPnNiI") {
+		return 0, fmt.Errorf("%w: %s must be a decimal number", ErrInvalidCoordinates, name)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be a decimal number", ErrInvalidCoordinates, name)
+	}
+
+	if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 > MaxCoordinateDecimals {
+		return 0, fmt.Errorf("%w: %s has more than %d decimal places", ErrInvalidCoordinates, name, MaxCoordinateDecimals)
+	}
+	if v < -limit || v > limit {
+		return 0, fmt.Errorf("%w: %s must be between %g and %g", ErrInvalidCoordinates, name, -limit, limit)
+	}
+	return v, nil
+}
@@ -0,0 +1,3 @@
+module example.com/openmeteo
+
+go 1.24.4
@@ -0,0 +1,97 @@
+package openmeteo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Unit identifiers, named as in Open-Meteo's temperature_unit,
+// precipitation_unit and wind_speed_unit parameters.
+const (
+	Celsius    = "celsius"
+	Fahrenheit = "fahrenheit"
+
+	Millimeters = "mm"
+	Inches      = "inch"
+
+	KilometersPerHour = "kmh"
+	MetersPerSecond   = "ms"
+	MilesPerHour      = "mph"
+	Knots             = "kn"
+)
+
+// Unit systems accepted by ParseUnits.
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+var ErrInvalidUnits = errors.New("invalid units")
+
+// Units is the unit each quantity is reported in.
+type Units struct {
+	Temperature   string
+	Precipitation string
+	WindSpeed     string
+}
+
+// MetricUnits is Open-Meteo's default, and what the server's providers and
+// cache entries use.
+var MetricUnits = Units{Temperature: Celsius, Precipitation: Millimeters, WindSpeed: KilometersPerHour}
+
+// ImperialUnits is °F, inches and mph.
+var ImperialUnits = Units{Temperature: Fahrenheit, Precipitation: Inches, WindSpeed: MilesPerHour}
+
+// ParseUnits builds Units from a unit system ("metric", "imperial", or empty
+// for metric) and optional per-quantity overrides, e.g. imperial with
+// temperature "celsius". Unknown names return ErrInvalidUnits.
+func ParseUnits(system, temperature, precipitation, windSpeed string) (Units, error) {
+	var u Units
+	switch system {
+	case "", UnitsMetric:
+		u = MetricUnits
+	case UnitsImperial:
+		u = ImperialUnits
+	default:
+		return Units{}, fmt.Errorf("%w: units must be %s or %s", ErrInvalidUnits, UnitsMetric, UnitsImperial)
+	}
+
+	overrides := []struct {
+		name    string
+		value   string
+		allowed []string
+		target  *string
+	}{
+		{"temperature_unit", temperature, []string{Celsius, Fahrenheit}, &u.Temperature},
+		{"precipitation_unit", precipitation, []string{Millimeters, Inches}, &u.Precipitation},
+		{"wind_speed_unit", windSpeed, []string{KilometersPerHour, MetersPerSecond, MilesPerHour, Knots}, &u.WindSpeed},
+	}
+	for _, o := range overrides {
+		if o.value == "" {
+			continue
+		}
+		if !slices.Contains(o.allowed, o.value) {
+			return Units{}, fmt.Errorf("%w: %s must be one of %v", ErrInvalidUnits, o.name, o.allowed)
+		}
+		*o.target = o.value
+	}
+	return u, nil
+}
+
+// compassPoints are the 16 points of the compass, clockwise from north.
+var compassPoints = [16]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// CompassPoint names a wind direction in degrees (0 = north, 90 = east)
+// as the nearest of the 16 compass points, e.g. 112 -> "ESE".
+func CompassPoint(degrees float64) string {
+	i := int(math.Round(math.Mod(degrees, 360)/22.5)) % 16
+	if i < 0 {
+		i += 16
+	}
+	return compassPoints[i]
+}
@@ -0,0 +1,51 @@
+// Package openmeteo builds requests for the Open-Meteo forecast API. It is
+// shared by the CLI, which calls Open-Meteo directly, and the server's
+// provider, so both always ask for exactly the same variables.
+package openmeteo
+
+import "fmt"
+
+// ForecastURL is the public Open-Meteo forecast endpoint.
+const ForecastURL = "https://api.open-meteo.com/v1/forecast"
+
+// Variables requested from Open-Meteo for each kind of data. The server's
+// decoders expect exactly these; change both together.
+const (
+	CurrentParams = "temperature_2m,weather_code,relative_humidity_2m,rain,precipitation_probability,is_day,apparent_temperature,wind_speed_10m," +
+		"wind_gusts_10m,wind_direction_10m,surface_pressure,cloud_cover,visibility,dew_point_2m,uv_index"
+	HourlyParams = "temperature_2m,apparent_temperature,precipitation_probability,rain,weather_code,is_day,wind_speed_10m"
+	DailyParams  = "temperature_2m_max,temperature_2m_min,precipitation_sum,weather_code,sunrise,sunset,uv_index_max,wind_speed_10m_max"
+)
+
+// CurrentURL builds the request URL for current conditions at a coordinate.
+// base is the forecast endpoint, ForecastURL or a self-hosted mirror.
+func CurrentURL(base string, lat, lon float64) string {
+	return fmt.Sprintf("%s?latitude=%f&longitude=%f&current=%s&timezone=auto", base, lat, lon, CurrentParams)
+}
+
+// CurrentURLIn is CurrentURL with Open-Meteo converting the values to u.
+// The server always asks for metric and converts itself (so one cache entry
+// serves every unit system); the CLI has no cache and lets Open-Meteo do it.
+func CurrentURLIn(base string, lat, lon float64, u Units) string {
+	uri := CurrentURL(base, lat, lon)
+	if u.Temperature != MetricUnits.Temperature {
+		uri += "&temperature_unit=" + u.Temperature
+	}
+	if u.Precipitation != MetricUnits.Precipitation {
+		uri += "&precipitation_unit=" + u.Precipitation
+	}
+	if u.WindSpeed != MetricUnits.WindSpeed {
+		uri += "&wind_speed_unit=" + u.WindSpeed
+	}
+	return uri
+}
+
+// HourlyURL builds the request URL for `hours` hours of hourly data.
+func HourlyURL(base string, lat, lon float64, hours int) string {
+	return fmt.Sprintf("%s?latitude=%f&longitude=%f&hourly=%s&forecast_hours=%d&timezone=auto", base, lat, lon, HourlyParams, hours)
+}
+
+// DailyURL builds the request URL for `days` days of daily data.
+func DailyURL(base string, lat, lon float64, days int) string {
+	return fmt.Sprintf("%s?latitude=%f&longitude=%f&daily=%s&forecast_days=%d&timezone=auto", base, lat, lon, DailyParams, days)
+}
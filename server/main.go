@@ -4,72 +4,973 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 	"weather-cli/server/pkg/cache"
+	"weather-cli/server/pkg/config"
+	"weather-cli/server/pkg/logging"
+	"weather-cli/server/pkg/metrics"
+	"weather-cli/server/pkg/tracing"
 	"weather-cli/server/pkg/weather"
+
+	"example.com/locations"
+	"example.com/openmeteo"
+	weather_codes "example.com/weather_codes"
 )
 
-func weatherHandler(w http.ResponseWriter, r *http.Request) {
+// allowCORS sets the CORS headers shared by every endpoint and answers
+// preflight requests. Returns true if the request was fully handled.
+func allowCORS(w http.ResponseWriter, r *http.Request) bool {
 	// Allow browser requests from any origin (CORS).
 	// Needed so the upcoming web UI can call this API directly.
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, traceparent")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 	// Respond quickly to preflight requests.
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	return false
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends a JSON error body like {"error":"city not found"}.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeFreshnessHeaders adds HTTP caching headers describing how old a response is:
+// Age (seconds since the data was fetched upstream) and, for stale data, a
+// Warning header so clients and proxies know it is past its freshness lifetime.
+func writeFreshnessHeaders(w http.ResponseWriter, f weather.Freshness) {
+	if !f.FetchedAt.IsZero() {
+		age := time.Since(f.FetchedAt)
+		if age < 0 {
+			age = 0
+		}
+		w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	}
+	if f.Stale {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+}
+
+// writeWeatherError maps package-level errors from weather to proper HTTP codes.
+func writeWeatherError(w http.ResponseWriter, r *http.Request, err error) {
+	status, body := weatherErrorBody(r.Context(), err)
+	if status == http.StatusServiceUnavailable {
+		// Every provider's breaker is open: tell clients to back off
+		w.Header().Set("Retry-After", "30")
+	}
+	writeJSON(w, status, body)
+}
+
+// weatherErrorBody is the HTTP status and JSON error body for an error from
+// weather. Batch responses use it for each failed item.
+func weatherErrorBody(ctx context.Context, err error) (int, map[string]any) {
+	var notFound *weather.CityNotFoundError
+	switch {
+	case errors.As(err, &notFound):
+		// 404 with "did you mean" names for typos like "banglore"
+		return http.StatusNotFound, map[string]any{
+			"error":        "city not found",
+			"did_you_mean": notFound.Suggestions,
+		}
+	case errors.Is(err, weather.ErrCityNotFound):
+		return http.StatusNotFound, map[string]any{"error": "city not found"}
+	case errors.Is(err, weather.ErrInvalidCoordinates), errors.Is(err, weather.ErrInvalidBatch):
+		return http.StatusBadRequest, map[string]any{"error": err.Error()}
+	case errors.Is(err, weather.ErrNoProviderAvailable):
+		return http.StatusServiceUnavailable, map[string]any{"error": "weather providers unavailable"}
+	default:
+		slog.ErrorContext(ctx, "weather request failed", "err", err)
+		return http.StatusInternalServerError, map[string]any{"error": "upstream or server error"}
+	}
+}
+
+// requestLocale picks the response language: ?lang= if given, otherwise the
+// Accept-Language header. Languages we have no translations for get English.
+// The choice is reported in Content-Language.
+func requestLocale(w http.ResponseWriter, r *http.Request) string {
+	// The same URL answers in different languages depending on the header
+	w.Header().Add("Vary", "Accept-Language")
+
+	accept := r.URL.Query().Get("lang")
+	if accept == "" {
+		accept = r.Header.Get("Accept-Language")
+	}
+	locale := weather_codes.MatchLocale(accept)
+	w.Header().Set("Content-Language", locale)
+	return locale
+}
+
+// requestUnits reads ?units=metric|imperial and the per-quantity overrides
+// (temperature_unit, precipitation_unit, wind_speed_unit), e.g.
+// ?units=imperial&temperature_unit=celsius.
+func requestUnits(r *http.Request) (weather.Units, error) {
+	q := r.URL.Query()
+	return openmeteo.ParseUnits(q.Get("units"), q.Get("temperature_unit"), q.Get("precipitation_unit"), q.Get("wind_speed_unit"))
+}
+
+// queryInt parses an optional positive integer query parameter,
+// returning def when it is absent.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return n, nil
+}
+
+// handlerTimeout bounds each request, upstream calls included
+// (server.handler_timeout).
+var handlerTimeout = 15 * time.Second
+
+func weatherHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	if cities := q["city"]; len(cities) > 1 {
+		// GET /weather?city=a&city=b is a batch request
+		queries := make([]weather.BatchQuery, len(cities))
+		for i, c := range cities {
+			queries[i] = weather.BatchQuery{City: c}
+		}
+		serveWeatherBatch(w, r, queries)
+		return
+	}
+
+	city := q.Get("city")
+	_, hasLat := q["lat"]
+	_, hasLon := q["lon"]
+	byCoords := hasLat || hasLon
+	if byCoords && city != "" {
+		writeError(w, http.StatusBadRequest, "use either city or lat/lon, not both")
+		return
+	}
+
+	units, err := requestUnits(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// ?fields=temperature,wind_speed trims the response to just those fields
+	fields, err := weather.ParseFields(q.Get("fields"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := weather.WithUnits(weather.WithLocale(r.Context(), requestLocale(w, r)), units)
+	ctx, cancel := context.WithTimeout(ctx, handlerTimeout)
+	defer cancel()
+
+	var resp weather.WeatherResp
+	if byCoords {
+		// GET /weather?lat=..&lon=.. skips the city lookup entirely
+		var lat, lon float64
+		lat, lon, err = openmeteo.ParseCoordinates(q.Get("lat"), q.Get("lon"))
+		if err == nil {
+			resp, err = weather.GetWeatherAt(ctx, lat, lon)
+		}
+	} else {
+		resp, err = weather.GetWeather(ctx, city)
+	}
+	if err != nil {
+		writeWeatherError(w, r, err)
+		return
+	}
+
+	body, err := weather.SelectFields(resp, fields)
+	if err != nil {
+		writeWeatherError(w, r, err)
+		return
+	}
+
+	writeFreshnessHeaders(w, resp.Freshness)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// maxBatchBodyBytes caps the size of a POST /weather/batch body.
+const maxBatchBodyBytes = 64 << 10
+
+// weatherBatchHandler serves POST /weather/batch with a body like
+//
+//	{"locations": [{"city": "chennai"}, {"lat": 13.08, "lon": 80.27}]}
+//
+// or just {"cities": ["chennai", "mumbai"]}. Query parameters (units, lang,
+// fields) apply to every location.
+func weatherBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+
+	var body struct {
+		Locations []weather.BatchQuery `json:"locations"`
+		Cities    []string             `json:"cities"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	queries := body.Locations
+	for _, c := range body.Cities {
+		queries = append(queries, weather.BatchQuery{City: c})
+	}
+	serveWeatherBatch(w, r, queries)
+}
+
+// serveWeatherBatch fetches the weather for every query and writes one
+// result per query, in order:
+//
+//	{"results": [
+//	  {"query": "chennai", "status": 200, "weather": {...}},
+//	  {"query": "atlantis", "status": 404, "error": "city not found", "did_you_mean": [...]}
+//	]}
+//
+// The response is 200 even if some (or all) items failed; only a malformed
+// batch is rejected as a whole.
+func serveWeatherBatch(w http.ResponseWriter, r *http.Request, queries []weather.BatchQuery) {
+	if err := weather.ValidateBatch(queries); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	units, err := requestUnits(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := weather.ParseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := weather.WithUnits(weather.WithLocale(r.Context(), requestLocale(w, r)), units)
+	ctx, cancel := context.WithTimeout(ctx, handlerTimeout)
+	defer cancel()
+
+	results := weather.GetWeatherBatch(ctx, queries)
+
+	items := make([]map[string]any, len(results))
+	for i, res := range results {
+		item := map[string]any{"query": res.Query.String()}
+		var body any
+		if res.Err == nil {
+			body, res.Err = weather.SelectFields(res.Weather, fields)
+		}
+		if res.Err != nil {
+			status, errBody := weatherErrorBody(ctx, res.Err)
+			maps.Copy(item, errBody)
+			item["status"] = status
+		} else {
+			item["status"] = http.StatusOK
+			item["weather"] = body
+		}
+		items[i] = item
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"results": items})
+}
+
+// hourlyForecastHandler serves GET /forecast/hourly?city=...&hours=N
+func hourlyForecastHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
 		return
 	}
 
 	city := r.URL.Query().Get("city")
-	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	hours, err := queryInt(r, "hours", weather.DefaultHourlyHours)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if hours < 1 || hours > weather.MaxHourlyHours {
+		writeError(w, http.StatusBadRequest, weather.ErrInvalidHours.Error())
+		return
+	}
+
+	units, err := requestUnits(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := weather.WithUnits(weather.WithLocale(r.Context(), requestLocale(w, r)), units)
+	ctx, cancel := context.WithTimeout(ctx, handlerTimeout)
 	defer cancel()
 
-	resp, err := weather.GetWeather(ctx, city)
+	resp, err := weather.GetHourlyForecast(ctx, city, hours)
 	if err != nil {
-		// map package-level errors to proper HTTP codes
-		if errors.Is(err, weather.ErrCityNotFound) {
-			w.Header().Set("Content-Type", "application/json")
-			// CORS header already set above
-			http.Error(w, `{"error":"city not found"}`, http.StatusNotFound)
+		writeWeatherError(w, r, err)
+		return
+	}
+
+	writeFreshnessHeaders(w, resp.Freshness)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// dailyForecastHandler serves GET /forecast/daily?city=...&days=N
+func dailyForecastHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
+		return
+	}
+
+	city := r.URL.Query().Get("city")
+	days, err := queryInt(r, "days", weather.DefaultDailyDays)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if days < 1 || days > weather.MaxDailyDays {
+		writeError(w, http.StatusBadRequest, weather.ErrInvalidDays.Error())
+		return
+	}
+
+	units, err := requestUnits(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := weather.WithUnits(weather.WithLocale(r.Context(), requestLocale(w, r)), units)
+	ctx, cancel := context.WithTimeout(ctx, handlerTimeout)
+	defer cancel()
+
+	resp, err := weather.GetDailyForecast(ctx, city, days)
+	if err != nil {
+		writeWeatherError(w, r, err)
+		return
+	}
+
+	writeFreshnessHeaders(w, resp.Freshness)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// nearestLocationsHandler serves GET /locations/nearest?lat=..&lon=..&n=5 with
+// the closest known cities and their great-circle distances in km.
+func nearestLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	lat, lon, err := openmeteo.ParseCoordinates(q.Get("lat"), q.Get("lon"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	n, err := queryInt(r, "n", 5)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if n < 1 || n > weather.MaxNearestCities {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("n must be between 1 and %d", weather.MaxNearestCities))
+		return
+	}
+
+	nearest, err := weather.NearestCities(lat, lon, n)
+	if err != nil {
+		writeWeatherError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"lat":    lat,
+		"lon":    lon,
+		"cities": nearest,
+	})
+}
+
+// searchLocationsHandler serves GET /locations/search?q=..&limit=10 with known
+// cities ranked by how well they match q, for autocomplete.
+func searchLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit, err := queryInt(r, "limit", weather.DefaultSearchLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit < 1 || limit > weather.MaxSearchLimit {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", weather.MaxSearchLimit))
+		return
+	}
+
+	matches, err := weather.SearchCities(q, limit)
+	if err != nil {
+		writeWeatherError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"query":   q,
+		"results": matches,
+	})
+}
+
+// providersDiagnosticsHandler serves GET /diagnostics/providers with the
+// health and circuit breaker state of each upstream weather provider.
+func providersDiagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"providers": weather.ProvidersStatus(),
+	})
+}
+
+// HTTP and gazetteer metrics. Cache and upstream metrics live in their packages.
+var (
+	httpRequests = metrics.NewCounterVec("weather_http_requests_total",
+		"HTTP requests served, by route, method and status code.", "route", "method", "status")
+	httpDuration = metrics.NewHistogramVec("weather_http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by route and status code.", metrics.DefBuckets, "route", "status")
+	gazetteerReloads = metrics.NewCounterVec("weather_gazetteer_reloads_total",
+		"Gazetteer reloads after the initial load, by result (ok, error).", "result")
+)
+
+// statusRecorder remembers the status code a handler wrote.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// probeRoutes are polled by orchestrators and Prometheus every few seconds,
+// so their requests are only logged at debug level, and not traced.
+var probeRoutes = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// handle registers h for route, counting and timing every request it serves.
+// The route pattern, not the request path, is the metric label, so the
+// number of series stays fixed whatever URLs clients send.
+//
+// Each request gets an ID: the caller's X-Request-ID if it sent a usable one,
+// otherwise a new one. It is echoed in the X-Request-ID response header and
+// carried in the request context, so every log line the request causes (cache
+// lookups, upstream calls) has the same request_id.
+//
+// Each request is also the root span of a trace (or a child of the caller's
+// span, if it sent a traceparent header); the cache, gazetteer and upstream
+// spans the handler causes hang off it.
+func handle(route string, h http.HandlerFunc) {
+	http.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get("X-Request-ID")
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := logging.WithRequestID(r.Context(), id)
+		r = r.WithContext(ctx)
+
+		var span *tracing.Span
+		if !probeRoutes[route] {
+			ctx, span = tracing.StartServer(r, r.Method+" "+route,
+				tracing.String("http.method", r.Method), tracing.String("http.route", route), tracing.String("request_id", id))
+			r = r.WithContext(ctx)
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r)
+		elapsed := time.Since(start)
+
+		status := strconv.Itoa(rec.status)
+		httpRequests.Inc(route, methodLabel(r.Method), status)
+		httpDuration.Observe(elapsed.Seconds(), route, status)
+
+		if span != nil {
+			span.SetAttributes(tracing.Int("http.status_code", rec.status))
+			if rec.status >= http.StatusInternalServerError {
+				span.RecordError(errors.New(http.StatusText(rec.status)))
+			}
+			span.End()
+		}
+
+		level := slog.LevelInfo
+		if probeRoutes[route] {
+			level = slog.LevelDebug
+		}
+		slog.Log(ctx, level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", r.URL.RawQuery,
+			"status", rec.status,
+			logging.Duration(elapsed),
+		)
+	})
+}
+
+// methodLabel keeps the method label to the methods this API answers, so
+// clients sending made-up methods can't create new series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// healthzHandler serves GET /healthz, the liveness probe. It only says the
+// process is up and serving HTTP: a Redis or upstream outage must not get
+// the server restarted, since it degrades gracefully through both.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if allowCORS(w, r) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readinessCheck is the result of one /readyz check.
+type readinessCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// readyzPingTimeout bounds the cache ping, so a hung Redis fails the probe
+// quickly instead of hanging it.
+const readyzPingTimeout = 2 * time.Second
+
+// readyzHandler returns the handler for GET /readyz, the readiness probe.
+// It answers 200 only when weather requests can be served:
+//   - gazetteer: the city list is loaded and not empty
+//   - cache:     the configured cache answers a ping (passes when caching is off)
+//   - providers: at least one provider's circuit breaker is not open
+//
+// Otherwise it answers 503. Either way the body has every check's result.
+func readyzHandler(store *locations.Store, backend cache.Backend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if allowCORS(w, r) {
 			return
 		}
-		log.Printf("GetWeather error: %v\n", err)
-		w.Header().Set("Content-Type", "application/json")
-		http.Error(w, `{"error":"upstream or server error"}`, http.StatusInternalServerError)
+
+		checks := map[string]readinessCheck{
+			"gazetteer": checkGazetteer(store),
+			"cache":     checkCache(r.Context(), backend),
+			"providers": checkProviders(),
+		}
+
+		ready := true
+		for _, c := range checks {
+			ready = ready && c.OK
+		}
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, map[string]any{"ready": ready, "checks": checks})
+	}
+}
+
+func checkGazetteer(store *locations.Store) readinessCheck {
+	n := store.Current().Gazetteer.Len()
+	if n == 0 {
+		return readinessCheck{Detail: "no cities loaded from " + store.Path()}
+	}
+	return readinessCheck{OK: true, Detail: fmt.Sprintf("%d cities from %s", n, store.Path())}
+}
+
+func checkCache(ctx context.Context, backend cache.Backend) readinessCheck {
+	if backend == nil {
+		return readinessCheck{OK: true, Detail: "caching disabled"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readyzPingTimeout)
+	defer cancel()
+
+	if err := backend.Ping(ctx); err != nil {
+		return readinessCheck{Detail: err.Error()}
+	}
+	return readinessCheck{OK: true, Detail: "reachable"}
+}
+
+func checkProviders() readinessCheck {
+	statuses := weather.ProvidersStatus()
+	available := 0
+	for _, s := range statuses {
+		// Half-open counts: the next request is the probe that closes it again
+		if s.State != weather.BreakerOpen {
+			available++
+		}
+	}
+	detail := fmt.Sprintf("%d of %d providers available", available, len(statuses))
+	return readinessCheck{OK: available > 0, Detail: detail}
+}
+
+// setupCache builds the cache backend chosen by cache.backend and wires it
+// into the weather package:
+//   - memory: in-process LRU, no external service needed
+//   - redis:  shared Redis cache (default)
+//   - tiered: in-process LRU in front of Redis
+//   - none:   no caching
+//
+// If Redis is unreachable, redis and tiered fall back to the in-memory cache
+// rather than running uncached. Returns nil when caching is disabled.
+func setupCache(cfg config.Cache, redisCfg config.Redis) cache.Backend {
+	// TTLs apply to every backend, so set them before anything is cached
+	ttls := []struct {
+		ns           cache.Namespace
+		fresh, stale time.Duration
+	}{
+		{cache.NamespaceCurrent, cfg.CurrentTTL, cfg.CurrentStaleTTL},
+		{cache.NamespaceHourly, cfg.HourlyTTL, cfg.HourlyStaleTTL},
+		{cache.NamespaceDaily, cfg.DailyTTL, cfg.DailyStaleTTL},
+	}
+	for _, t := range ttls {
+		if err := cache.SetTTLs(t.ns, t.fresh, t.stale); err != nil {
+			fatal("invalid cache TTLs", "err", err)
+		}
+	}
+
+	// Each backend is wrapped so its hits, misses and errors show up in /metrics.
+	// At most one memory cache is ever built, so its gauges register once.
+	newMemory := func() cache.Backend {
+		m := cache.NewMemory(cfg.MemoryMaxEntries, cfg.MemoryMaxBytes)
+		metrics.NewGaugeFunc("weather_cache_memory_entries", "Entries held by the in-memory cache.", func() float64 {
+			entries, _ := m.Stats()
+			return float64(entries)
+		})
+		metrics.NewGaugeFunc("weather_cache_memory_bytes", "Bytes of data held by the in-memory cache.", func() float64 {
+			_, bytes := m.Stats()
+			return float64(bytes)
+		})
+		return cache.Instrument("memory", m)
+	}
+
+	var client cache.Backend
+	var redisClient *cache.Client
+	switch cfg.Backend {
+	case "none":
+		slog.Warn("cache disabled, API calls will not be cached", "backend", cfg.Backend)
+		return nil
+	case "memory":
+		slog.Info("using in-memory cache")
+		client = newMemory()
+	case "redis", "tiered":
+		slog.Info("connecting to Redis", "addr", redisCfg.Addr, "db", redisCfg.DB)
+		var err error
+		redisClient, err = cache.NewClient(redisCfg.Addr, redisCfg.Password, redisCfg.DB)
+		if err != nil {
+			slog.Warn("failed to connect to Redis, falling back to in-memory cache; entries won't be shared between instances", "err", err)
+			client = newMemory()
+			break
+		}
+		slog.Info("Redis connected")
+
+		if cfg.Backend == "tiered" {
+			slog.Info("using tiered cache (memory + Redis)")
+			client = cache.NewTiered(newMemory(), cache.Instrument("redis", redisClient))
+		} else {
+			client = cache.Instrument("redis", redisClient)
+		}
+	default:
+		fatal("unknown cache backend (want memory, redis, tiered or none)", "backend", cfg.Backend)
+	}
+
+	// Set the cache client for weather package to use
+	weather.SetCacheClient(client)
+
+	// Key mode grid|geohash keys entries on the resolved coordinates,
+	// so every alias of a city (and nearby lat/lon queries) share one entry
+	keyer, err := cache.NewKeyer(cfg.KeyMode, cfg.GridSize, cfg.GeohashPrecision)
+	if err != nil {
+		fatal("invalid cache key settings", "err", err)
+	}
+	weather.SetCacheKeyer(keyer)
+
+	// Stale-while-revalidate is on by default; turning it off
+	// makes expired entries plain cache misses again
+	if !cfg.ServeStale {
+		slog.Info("serving stale cache entries disabled")
+		weather.SetServeStale(false)
+	}
+
+	// Optionally coordinate cache misses across server instances,
+	// so N replicas missing the same key make one upstream call
+	if redisClient != nil && cfg.DistributedLock {
+		slog.Info("distributed fetch lock enabled")
+		weather.SetLocker(redisClient)
+	}
+
+	return client
+}
+
+// setupProviders picks the upstream weather sources, in priority order.
+// The fixture provider serves recorded responses (offline mode), and a
+// secondary URL adds an Open-Meteo compatible mirror to fail over to.
+func setupProviders(cfg config.Provider) {
+	weather.SetHTTPTimeout(cfg.HTTPTimeout)
+
+	var providers []weather.Provider
+	switch cfg.Name {
+	case "openmeteo":
+		providers = append(providers, weather.NewOpenMeteo())
+	case "fixture":
+		providers = append(providers, weather.NewFixture(cfg.FixtureDir))
+	default:
+		fatal("unknown weather provider (want openmeteo or fixture)", "provider", cfg.Name)
+	}
+	if cfg.SecondaryURL != "" {
+		providers = append(providers, &weather.OpenMeteo{BaseURL: cfg.SecondaryURL})
+	}
+
+	failover := weather.NewFailoverWithBreakers(cfg.BreakerThreshold, cfg.BreakerCooldown, providers...)
+	failover.AttemptTimeout = cfg.AttemptTimeout
+	weather.SetFailover(failover)
+	for i, p := range providers {
+		slog.Info("weather provider", "priority", i+1, "provider", p.Name())
+	}
+}
+
+// setupTracing picks where request traces are exported: nowhere (the
+// default; traceparent is still propagated), stdout or a file as JSON lines
+// for local debugging, or an OpenTelemetry Collector over OTLP/HTTP.
+func setupTracing(cfg config.Tracing) {
+	if err := tracing.SetSampleRatio(cfg.SampleRatio); err != nil {
+		fatal("invalid tracing settings", "err", err)
+	}
+
+	switch cfg.Exporter {
+	case "none":
 		return
+	case "stdout":
+		tracing.SetExporter(tracing.NewWriterExporter(os.Stdout, false))
+		slog.Info("tracing to stdout", "sample_ratio", cfg.SampleRatio)
+	case "file":
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fatal("failed to open tracing file", "err", err)
+		}
+		tracing.SetExporter(tracing.NewWriterExporter(f, true))
+		slog.Info("tracing to file", "path", cfg.File, "sample_ratio", cfg.SampleRatio)
+	case "otlp":
+		tracing.SetExporter(tracing.NewOTLPExporter(cfg.OTLPEndpoint, cfg.ServiceName))
+		slog.Info("tracing to OTLP collector", "endpoint", cfg.OTLPEndpoint, "service", cfg.ServiceName, "sample_ratio", cfg.SampleRatio)
+	default:
+		fatal("unknown tracing exporter (want none, stdout, file or otlp)", "exporter", cfg.Exporter)
 	}
+}
 
-	json.NewEncoder(w).Encode(resp)
+// setupGazetteer loads the city list once and keeps it fresh. With no file
+// the list built into the binary is used. A file is reloaded on SIGHUP, and
+// when it changes (polled every reloadInterval, 0 to disable). A bad edit is
+// logged and the last good copy keeps serving.
+func setupGazetteer(citiesFile string, reloadInterval time.Duration) *locations.Store {
+	store, err := locations.NewStore(citiesFile)
+	if err != nil {
+		fatal("failed to load city gazetteer", "err", err)
+	}
+	weather.SetGazetteer(store)
+	slog.Info("loaded cities", "cities", store.Current().Gazetteer.Len(), "path", store.Path())
+
+	metrics.NewGaugeFunc("weather_gazetteer_cities", "Cities in the loaded gazetteer.", func() float64 {
+		return float64(store.Current().Gazetteer.Len())
+	})
+	metrics.NewGaugeFunc("weather_gazetteer_loaded_timestamp_seconds", "Unix time the gazetteer in use was loaded.", func() float64 {
+		return float64(store.Current().LoadedAt.UnixNano()) / 1e9
+	})
+	if citiesFile == "" {
+		// Nothing on disk to watch
+		return store
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var poll <-chan time.Time
+	if reloadInterval > 0 {
+		poll = time.NewTicker(reloadInterval).C
+	}
+
+	go func() {
+		for {
+			var changed bool
+			var err error
+			select {
+			case <-hup:
+				changed, err = true, store.Reload()
+			case <-poll:
+				changed, err = store.ReloadIfChanged()
+			}
+
+			switch {
+			case err != nil:
+				gazetteerReloads.Inc("error")
+				slog.Warn("gazetteer reload failed, keeping the previous cities", "cities", store.Current().Gazetteer.Len(), "err", err)
+			case changed:
+				gazetteerReloads.Inc("ok")
+				slog.Info("reloaded cities", "cities", store.Current().Gazetteer.Len(), "path", store.Path())
+			}
+		}
+	}()
+
+	return store
 }
 
 func main() {
-	// Initialize Redis cache
-	// Read Redis configuration from environment variables with defaults
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "localhost:6379" // Default Redis address
-	}
-	redisPassword := os.Getenv("REDIS_PASSWORD") // Empty if no password
+	// Settings come from defaults, then -config / CONFIG_FILE, then env vars, then flags
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-	log.Printf("Connecting to Redis at %s...", redisAddr)
-	cacheClient, err := cache.NewClient(redisAddr, redisPassword, 0)
+	cfg, err := flags.Load()
 	if err != nil {
-		log.Printf("⚠️  Failed to connect to Redis: %v", err)
-		log.Printf("⚠️  Running WITHOUT cache - API calls will not be cached")
-	} else {
-		log.Printf("✅ Redis connected successfully")
-		// Set the cache client for weather package to use
-		weather.SetCacheClient(cacheClient)
-		defer cacheClient.Close()
+		fatal("invalid configuration", "err", err)
+	}
+	if err := logging.Setup(os.Stderr, cfg.Log.Format, cfg.Log.Level); err != nil {
+		fatal("invalid log settings", "err", err)
+	}
+	if flags.Print {
+		if err := cfg.WriteTOML(os.Stdout); err != nil {
+			fatal("failed to print configuration", "err", err)
+		}
+		return
+	}
+	if flags.File != "" {
+		slog.Info("loaded configuration", "path", flags.File)
+	}
+
+	// Data files default to the copies embedded in the binary, so it runs from any directory
+	weather_codes.WEATHER_CODES_FILE_PATH = cfg.Data.WeatherCodesFile
+	if _, err := weather_codes.Codes(); err != nil {
+		fatal("failed to load weather codes", "err", err)
+	}
+
+	// Load the city list before serving lookups
+	store := setupGazetteer(cfg.Data.CitiesFile, cfg.Data.ReloadInterval)
+
+	// Initialize the cache (memory, redis, tiered or none)
+	cacheBackend := setupCache(cfg.Cache, cfg.Redis)
+
+	setupProviders(cfg.Provider)
+
+	setupTracing(cfg.Tracing)
+
+	// Batch requests fetch this many locations at a time
+	weather.SetBatchWorkers(cfg.Server.BatchWorkers)
+
+	// Every request (upstream calls included) must finish within this
+	handlerTimeout = cfg.Server.HandlerTimeout
+
+	handle("/weather", weatherHandler)
+	handle("/weather/batch", weatherBatchHandler)
+	handle("/forecast/hourly", hourlyForecastHandler)
+	handle("/forecast/daily", dailyForecastHandler)
+	handle("/locations/nearest", nearestLocationsHandler)
+	handle("/locations/search", searchLocationsHandler)
+	handle("/diagnostics/providers", providersDiagnosticsHandler)
+	handle("/healthz", healthzHandler)
+	handle("/readyz", readyzHandler(store, cacheBackend))
+	handle("/metrics", metrics.Handler().ServeHTTP)
+
+	srv := &http.Server{Addr: cfg.Server.Listen}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	slog.Info("server started", "listen", cfg.Server.Listen)
+
+	// Run until SIGINT (Ctrl+C) or SIGTERM (what orchestrators send on deploy)
+	signalCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	select {
+	case err := <-serveErr:
+		closeCache(cacheBackend)
+		fatal("server failed", "err", err)
+	case <-signalCtx.Done():
 	}
+	// A second signal kills the process right away instead of waiting for the drain
+	cancel()
+
+	shutdown(srv, cacheBackend, cfg.Server.ShutdownTimeout)
+}
+
+// fatal logs msg with its attributes at error level and exits, like log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// shutdown stops accepting connections, lets in-flight requests and background
+// cache refreshes finish (up to timeout in all), then closes the cache and
+// exports any spans still queued.
+func shutdown(srv *http.Server, cacheBackend cache.Backend, timeout time.Duration) {
+	slog.Info("shutting down, draining in-flight requests", "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	http.HandleFunc("/weather", weatherHandler)
-	addr := ":8080"
-	log.Printf("🚀 Go Server started, listening on http://localhost%s/", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("requests still running at the shutdown deadline were cut off", "err", err)
+	}
+	if err := weather.WaitForRefreshes(ctx); err != nil {
+		slog.Warn("background cache refreshes still running were abandoned", "err", err)
+	}
+	closeCache(cacheBackend)
+	if err := tracing.Shutdown(ctx); err != nil {
+		slog.Warn("failed to export the last spans", "err", err)
+	}
+
+	slog.Info("server stopped")
+}
+
+// closeCache closes the cache backend (Redis connections, memory sweeper), if any.
+func closeCache(cacheBackend cache.Backend) {
+	if cacheBackend == nil {
+		return
+	}
+	if err := cacheBackend.Close(); err != nil {
+		slog.Warn("failed to close cache", "err", err)
+	}
 }
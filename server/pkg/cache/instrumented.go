@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"weather-cli/server/pkg/logging"
+	"weather-cli/server/pkg/metrics"
+	"weather-cli/server/pkg/tracing"
+)
+
+// Cache metrics, labelled by backend ("memory", "redis") so each tier of a
+// Tiered cache is measured on its own.
+var (
+	cacheGets = metrics.NewCounterVec("weather_cache_gets_total",
+		"Cache lookups by backend and result (hit, stale, miss, error).", "backend", "result")
+	cacheSets = metrics.NewCounterVec("weather_cache_sets_total",
+		"Cache writes by backend and result (ok, error).", "backend", "result")
+	cacheSetBytes = metrics.NewCounterVec("weather_cache_set_bytes_total",
+		"Bytes of data written to the cache, by backend.", "backend")
+	cacheDuration = metrics.NewHistogramVec("weather_cache_operation_duration_seconds",
+		"Time taken by cache operations, by backend and operation (get, set).",
+		[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}, "backend", "op")
+)
+
+// Instrumented wraps a Backend and records metrics for every Get and Set,
+// logs each one at debug level with the caller's request ID, and traces it
+// as a span of the caller's request.
+type Instrumented struct {
+	Backend
+	name string
+}
+
+// Instrument wraps b so its traffic shows up in /metrics as backend=name.
+func Instrument(name string, b Backend) *Instrumented {
+	return &Instrumented{Backend: b, name: name}
+}
+
+// Get implements Backend.
+func (i *Instrumented) Get(ctx context.Context, ns Namespace, city string, at time.Time) (*Entry, error) {
+	ctx, span := tracing.Start(ctx, "cache.get",
+		tracing.String("cache.backend", i.name), tracing.String("cache.namespace", string(ns)), tracing.String("cache.key", city))
+	defer span.End()
+
+	start := time.Now()
+	entry, err := i.Backend.Get(ctx, ns, city, at)
+	elapsed := time.Since(start)
+	cacheDuration.Observe(elapsed.Seconds(), i.name, "get")
+
+	result := "hit"
+	switch {
+	case err != nil:
+		result = "error"
+	case entry == nil:
+		result = "miss"
+	case entry.Stale:
+		result = "stale"
+	}
+	cacheGets.Inc(i.name, result)
+	span.SetAttributes(tracing.String("cache.result", result))
+	span.RecordError(err)
+	slog.DebugContext(ctx, "cache get", "backend", i.name, "namespace", ns, "key", city, "result", result, logging.Duration(elapsed))
+	return entry, err
+}
+
+// Set implements Backend.
+func (i *Instrumented) Set(ctx context.Context, ns Namespace, city string, at time.Time, data []byte) error {
+	ctx, span := tracing.Start(ctx, "cache.set",
+		tracing.String("cache.backend", i.name), tracing.String("cache.namespace", string(ns)), tracing.String("cache.key", city),
+		tracing.Int("cache.bytes", len(data)))
+	defer span.End()
+
+	start := time.Now()
+	err := i.Backend.Set(ctx, ns, city, at, data)
+	span.RecordError(err)
+	elapsed := time.Since(start)
+	cacheDuration.Observe(elapsed.Seconds(), i.name, "set")
+	slog.DebugContext(ctx, "cache set", "backend", i.name, "namespace", ns, "key", city, "bytes", len(data), "ok", err == nil, logging.Duration(elapsed))
+
+	if err != nil {
+		cacheSets.Inc(i.name, "error")
+		return err
+	}
+	cacheSets.Inc(i.name, "ok")
+	cacheSetBytes.Add(float64(len(data)), i.name)
+	return nil
+}
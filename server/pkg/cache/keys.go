@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Defaults for coordinate-based keys.
+const (
+	// DefaultGridSize is the grid cell size in degrees (~5.5km of latitude).
+	DefaultGridSize = 0.05
+	// DefaultGeohashPrecision gives ~4.9km x 4.9km cells.
+	DefaultGeohashPrecision = 5
+)
+
+// Keyer turns a resolved location into the name of its cache entry.
+//
+// Keying on the city string means "mumbai", "Mumbai, IN" and "bombay" fill
+// three entries for the same coordinates. The coordinate keyers snap the
+// location to a cell instead, so every alias (and any nearby lat/lon query)
+// shares one entry.
+type Keyer interface {
+	Key(city string, lat, lon float64) string
+}
+
+// CityKeyer keys on the normalized city name (the original behaviour).
+type CityKeyer struct{}
+
+// Key implements Keyer.
+func (CityKeyer) Key(city string, lat, lon float64) string {
+	return strings.ToLower(strings.TrimSpace(city))
+}
+
+// GridKeyer snaps coordinates to a regular lat/lon grid of Size degrees.
+// Key format: "grid<size>:<lat-cell>,<lon-cell>", e.g. "grid0.05:381,1457"
+type GridKeyer struct {
+	Size float64
+}
+
+// Key implements Keyer.
+func (g GridKeyer) Key(city string, lat, lon float64) string {
+	return fmt.Sprintf("grid%g:%d,%d", g.Size, int(math.Floor(lat/g.Size)), int(math.Floor(lon/g.Size)))
+}
+
+// GeohashKeyer keys on a geohash prefix of Precision characters.
+// Key format: "geohash:<hash>", e.g. "geohash:te7ud"
+type GeohashKeyer struct {
+	Precision int
+}
+
+// Key implements Keyer.
+func (g GeohashKeyer) Key(city string, lat, lon float64) string {
+	return "geohash:" + Geohash(lat, lon, g.Precision)
+}
+
+// NewKeyer returns the keyer for a mode name: "city", "grid" or "geohash".
+// gridSize and precision only apply to their own mode.
+func NewKeyer(mode string, gridSize float64, precision int) (Keyer, error) {
+	switch mode {
+	case "", "city":
+		return CityKeyer{}, nil
+	case "grid":
+		if gridSize <= 0 || gridSize > 10 {
+			return nil, fmt.Errorf("grid size must be in (0, 10] degrees, got %g", gridSize)
+		}
+		return GridKeyer{Size: gridSize}, nil
+	case "geohash":
+		if precision < 1 || precision > 12 {
+			return nil, fmt.Errorf("geohash precision must be between 1 and 12, got %d", precision)
+		}
+		return GeohashKeyer{Precision: precision}, nil
+	}
+	return nil, fmt.Errorf("unknown cache key mode %q (want city, grid or geohash)", mode)
+}
+
+// geohashAlphabet is the base32 alphabet used by geohashes.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash encodes a coordinate as a geohash string of the given length.
+// Each character narrows the cell by interleaving longitude and latitude bits.
+func Geohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var sb strings.Builder
+	bit, ch := 0, 0
+	even := true // geohash starts with a longitude bit
+	for sb.Len() < precision {
+		if even {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if lon >= mid {
+				ch |= 1 << (4 - bit)
+				lonRange[0] = mid
+			} else {
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bit)
+				latRange[0] = mid
+			} else {
+				latRange[1] = mid
+			}
+		}
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			sb.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Defaults for NewMemory.
+const (
+	DefaultMemoryMaxEntries = 10000
+	DefaultMemoryMaxBytes   = 64 << 20 // 64 MiB of cached payloads
+	// memorySweepInterval is how often expired entries are purged in the background.
+	memorySweepInterval = time.Minute
+)
+
+// Memory is an in-process LRU cache with the same API and TTL semantics as
+// the Redis Client. It needs no external service, so small deployments get
+// caching for free, and it serves as the first tier of a Tiered cache.
+//
+// Entries are evicted when they pass their stale TTL, or least-recently-used
+// first once maxEntries or maxBytes is exceeded.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	ll         *list.List               // front = most recently used
+	items      map[string]*list.Element // key -> element holding *memoryItem
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// memoryItem is one cached value in a Memory cache.
+type memoryItem struct {
+	key       string
+	data      []byte
+	storedAt  time.Time
+	expiresAt time.Time
+}
+
+// NewMemory creates an in-memory cache holding at most maxEntries entries
+// and maxBytes bytes of data (0 means no limit for either), and starts a
+// background sweeper that drops expired entries. Call Close to stop it.
+func NewMemory(maxEntries, maxBytes int) *Memory {
+	m := &Memory{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		stop:       make(chan struct{}),
+	}
+	go m.sweepLoop()
+	return m
+}
+
+// Get retrieves the cached entry for a city in a namespace, judged at time `at`
+// Returns nil on a miss or if the entry is past its stale TTL.
+func (m *Memory) Get(ctx context.Context, ns Namespace, city string, at time.Time) (*Entry, error) {
+	key := buildKey(ns, city)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := el.Value.(*memoryItem)
+	if !at.Before(item.expiresAt) {
+		m.removeElement(el)
+		return nil, nil
+	}
+
+	m.ll.MoveToFront(el)
+	return newEntry(ns, item.data, item.storedAt, at), nil
+}
+
+// Set stores data fetched at time `at`, evicting least recently used entries
+// if the cache is over its limits.
+func (m *Memory) Set(ctx context.Context, ns Namespace, city string, at time.Time, data []byte) error {
+	key := buildKey(ns, city)
+	item := &memoryItem{
+		key:       key,
+		data:      data,
+		storedAt:  at,
+		expiresAt: at.Add(policyFor(ns).staleTTL),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.removeElement(el)
+	}
+	m.items[key] = m.ll.PushFront(item)
+	m.bytes += len(data)
+
+	for m.overLimit() {
+		m.removeElement(m.ll.Back())
+	}
+	return nil
+}
+
+// Len returns the number of entries currently cached.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ll.Len()
+}
+
+// Stats returns how many entries the cache holds and their total size in bytes.
+func (m *Memory) Stats() (entries, bytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ll.Len(), m.bytes
+}
+
+// Ping implements Backend. An in-process cache is always reachable.
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close stops the background sweeper. The cache stays usable.
+func (m *Memory) Close() error {
+	m.once.Do(func() { close(m.stop) })
+	return nil
+}
+
+// overLimit reports whether the cache holds more than its limits allow.
+// Must be called with m.mu held.
+func (m *Memory) overLimit() bool {
+	if m.ll.Len() == 0 {
+		return false
+	}
+	return (m.maxEntries > 0 && m.ll.Len() > m.maxEntries) ||
+		(m.maxBytes > 0 && m.bytes > m.maxBytes)
+}
+
+// removeElement drops an entry. Must be called with m.mu held.
+func (m *Memory) removeElement(el *list.Element) {
+	item := el.Value.(*memoryItem)
+	m.ll.Remove(el)
+	delete(m.items, item.key)
+	m.bytes -= len(item.data)
+}
+
+// sweepLoop periodically purges expired entries until Close is called.
+func (m *Memory) sweepLoop() {
+	ticker := time.NewTicker(memorySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.sweep(now)
+		}
+	}
+}
+
+// sweep removes every entry that expired before now.
+func (m *Memory) sweep(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for el := m.ll.Back(); el != nil; {
+		prev := el.Prev()
+		if !now.Before(el.Value.(*memoryItem).expiresAt) {
+			m.removeElement(el)
+		}
+		el = prev
+	}
+}
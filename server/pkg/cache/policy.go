@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Namespace groups cache keys by the kind of data they hold.
+// Each namespace has its own key prefix, time bucket and TTLs.
+type Namespace string
+
+const (
+	// NamespaceCurrent holds current-conditions snapshots (GET /weather).
+	NamespaceCurrent Namespace = "weather"
+	// NamespaceHourly holds hourly forecasts (GET /forecast/hourly).
+	NamespaceHourly Namespace = "hourly"
+	// NamespaceDaily holds daily forecasts (GET /forecast/daily).
+	NamespaceDaily Namespace = "daily"
+)
+
+// policy controls how long entries in a namespace live.
+// bucket: an entry stops being fresh when the clock enters a new bucket
+// freshTTL: an entry stops being fresh after this long, whatever the bucket
+// staleTTL: how long a stale entry is kept around to serve while refreshing
+// (or while the upstream is down) before it is deleted
+type policy struct {
+	bucket   time.Duration
+	freshTTL time.Duration
+	staleTTL time.Duration
+}
+
+// policies maps each namespace to its caching policy.
+// Current and hourly data follow Open-Meteo's 15-minute update cycle.
+// Daily aggregates (min/max, sunrise/sunset) barely move within a few hours,
+// so they are kept much longer to save upstream calls.
+var policies = map[Namespace]policy{
+	NamespaceCurrent: {bucket: 15 * time.Minute, freshTTL: 15 * time.Minute, staleTTL: 2 * time.Hour},
+	NamespaceHourly:  {bucket: 15 * time.Minute, freshTTL: 15 * time.Minute, staleTTL: 2 * time.Hour},
+	NamespaceDaily:   {bucket: 3 * time.Hour, freshTTL: 3 * time.Hour, staleTTL: 24 * time.Hour},
+}
+
+// TTLs returns how long entries in ns stay fresh, and how long they are kept
+// (and may be served stale) before being deleted.
+func TTLs(ns Namespace) (fresh, stale time.Duration) {
+	p := policyFor(ns)
+	return p.freshTTL, p.staleTTL
+}
+
+// SetTTLs overrides the TTLs of a namespace. The time bucket follows the
+// fresh TTL, as in the defaults. Call it before the cache is used.
+func SetTTLs(ns Namespace, fresh, stale time.Duration) error {
+	if fresh <= 0 || stale < fresh {
+		return fmt.Errorf("%s cache TTLs must satisfy 0 < fresh <= stale, got %v and %v", ns, fresh, stale)
+	}
+	policies[ns] = policy{bucket: fresh, freshTTL: fresh, staleTTL: stale}
+	return nil
+}
+
+// policyFor returns the policy for ns, falling back to the current-weather policy
+// for unknown namespaces.
+func policyFor(ns Namespace) policy {
+	if p, ok := policies[ns]; ok {
+		return p
+	}
+	return policies[NamespaceCurrent]
+}
+
+// roundToBucket rounds a timestamp down to the start of its bucket (in UTC)
+// Examples with a 15-minute bucket:
+//
+//	10:07 -> 10:00
+//	10:23 -> 10:15
+//	10:45 -> 10:45
+func roundToBucket(t time.Time, bucket time.Duration) time.Time {
+	return t.UTC().Truncate(bucket)
+}
+
+// keyVersion is bumped whenever the cached JSON changes shape, so a server
+// never decodes entries written by an older (or newer) one as its own.
+const keyVersion = "v4"
+
+// buildKey creates a cache key for a namespace and city
+// Format: "<namespace>:<version>:<city>"
+// Example: "weather:v4:mumbai"
+// Normalizes city name to prevent key fragmentation from mixed casing/whitespace
+// The key has no timestamp: freshness is tracked per entry (see Entry), so an
+// expired-but-stale value can still be found and served.
+func buildKey(ns Namespace, city string) string {
+	city = strings.ToLower(strings.TrimSpace(city))
+	return fmt.Sprintf("%s:%s:%s", ns, keyVersion, city)
+}
+
+// Entry is a cached value plus when it was stored.
+type Entry struct {
+	Data     []byte
+	StoredAt time.Time
+	// Stale is true once the entry is past its fresh TTL (or its time bucket
+	// has rolled over). Stale entries should be refreshed, but can still be served.
+	Stale bool
+}
+
+// newEntry builds an Entry for data stored at storedAt, as seen at time `at`.
+func newEntry(ns Namespace, data []byte, storedAt, at time.Time) *Entry {
+	p := policyFor(ns)
+	fresh := roundToBucket(storedAt, p.bucket).Equal(roundToBucket(at, p.bucket)) &&
+		at.Sub(storedAt) < p.freshTTL
+	return &Entry{Data: data, StoredAt: storedAt, Stale: !fresh}
+}
@@ -2,7 +2,10 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -36,6 +39,14 @@ func NewClient(addr, password string, db int) (*Client, error) {
 	return &Client{rdb: rdb}, nil
 }
 
+// Ping checks that Redis is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.rdb.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping failed: %w", err)
+	}
+	return nil
+}
+
 // Close closes the Redis connection
 func (c *Client) Close() error {
 	if c.rdb != nil {
@@ -44,62 +55,97 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// roundTo15Min rounds a timestamp down to the nearest 15-minute interval
-// Examples:
-//   10:07 -> 10:00
-//   10:23 -> 10:15
-//   10:45 -> 10:45
-func roundTo15Min(t time.Time) time.Time {
-	// Get minutes since the hour
-	minute := t.Minute()
-
-	// Round down to nearest 15-minute interval (0, 15, 30, 45)
-	roundedMinute := (minute / 15) * 15
-
-	// Return time with rounded minute and zero seconds/nanoseconds
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), roundedMinute, 0, 0, t.Location())
-}
+// Redis hash fields used for each cache entry.
+const (
+	fieldData     = "data"
+	fieldStoredAt = "stored_at"
+)
 
-// buildKey creates a cache key for a city and timestamp
-// Format: "weather:<city>:<rounded-timestamp>"
-// Example: "weather:mumbai:2025-10-03T10:15:00Z"
-// Normalizes city name to prevent key fragmentation from mixed casing/whitespace
-func buildKey(city string, t time.Time) string {
-	city = strings.ToLower(strings.TrimSpace(city))
-	rounded := roundTo15Min(t)
-	return fmt.Sprintf("weather:%s:%s", city, rounded.UTC().Format(time.RFC3339))
-}
+// Get retrieves the cached entry for a city in a namespace, judged at time `at`
+// Returns nil if cache miss (key doesn't exist or is past its stale TTL)
+// A returned entry has Stale set once it is past its fresh TTL.
+func (c *Client) Get(ctx context.Context, ns Namespace, city string, at time.Time) (*Entry, error) {
+	key := buildKey(ns, city)
 
-// Get retrieves cached weather data for a city at a specific timestamp
-// Returns nil if cache miss (key doesn't exist or expired)
-func (c *Client) Get(ctx context.Context, city string, at time.Time) ([]byte, error) {
-	key := buildKey(city, at)
+	vals, err := c.rdb.HMGet(ctx, key, fieldData, fieldStoredAt).Result()
+	if err != nil {
+		// Redis error (network, etc.)
+		return nil, fmt.Errorf("redis get failed: %w", err)
+	}
 
-	val, err := c.rdb.Get(ctx, key).Result()
-	if err == redis.Nil {
+	data, ok1 := vals[0].(string)
+	storedAtStr, ok2 := vals[1].(string)
+	if !ok1 || !ok2 {
 		// Cache miss - key doesn't exist
 		return nil, nil
 	}
+
+	storedAtMs, err := strconv.ParseInt(storedAtStr, 10, 64)
 	if err != nil {
-		// Redis error (network, etc.)
-		return nil, fmt.Errorf("redis get failed: %w", err)
+		return nil, fmt.Errorf("redis entry %s has bad %s: %w", key, fieldStoredAt, err)
 	}
 
-	return []byte(val), nil
+	return newEntry(ns, []byte(data), time.UnixMilli(storedAtMs), at), nil
 }
 
-// Set stores weather data in cache with 15-minute TTL
-// TTL (Time To Live) means Redis will automatically delete the key after 15 minutes
+// Set stores data fetched at time `at` in cache
+// The entry is fresh for the namespace's fresh TTL (15 minutes for current weather by default)
+// and Redis keeps it around until the longer stale TTL, then deletes it automatically
 // data should be the raw bytes to cache (e.g., JSON-encoded data)
-func (c *Client) Set(ctx context.Context, city string, at time.Time, data []byte) error {
-	key := buildKey(city, at)
-
-	// Set with 15-minute expiration
-	// After 15 minutes, Redis automatically deletes this key
-	err := c.rdb.Set(ctx, key, data, 15*time.Minute).Err()
+func (c *Client) Set(ctx context.Context, ns Namespace, city string, at time.Time, data []byte) error {
+	key := buildKey(ns, city)
+
+	// Store data + timestamp as a hash, and expire it after the stale TTL
+	// Both commands run in one MULTI/EXEC so there's never a key without expiry
+	_, err := c.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fieldData, data, fieldStoredAt, at.UnixMilli())
+		pipe.PExpire(ctx, key, policyFor(ns).staleTTL)
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("redis set failed: %w", err)
 	}
 
 	return nil
 }
+
+// unlockScript deletes a lock key only if it still holds our token,
+// so we never release a lock that expired and was taken by someone else.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// TryLock takes a short-lived fetch lock for a city in a namespace, so only one
+// server instance calls the upstream API when a popular key expires.
+// Key format: "lock:<namespace>:<city>"
+// The lock expires on its own after lease, even if the holder crashes.
+// Returns acquired=false (and no error) if another instance holds the lock.
+func (c *Client) TryLock(ctx context.Context, ns Namespace, city string, lease time.Duration) (func(), bool, error) {
+	key := fmt.Sprintf("lock:%s:%s", ns, strings.ToLower(strings.TrimSpace(city)))
+
+	// A random token identifies this holder
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, false, fmt.Errorf("lock token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	ok, err := c.rdb.SetNX(ctx, key, token, lease).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("redis lock failed: %w", err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// Use a fresh context: the request's may already be cancelled
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		unlockScript.Run(ctx, c.rdb, []string{key}, token)
+	}
+	return unlock, true, nil
+}
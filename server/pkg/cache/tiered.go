@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend is the interface shared by every cache store in this package
+// (Client for Redis, Memory, Tiered).
+type Backend interface {
+	Get(ctx context.Context, ns Namespace, city string, at time.Time) (*Entry, error)
+	Set(ctx context.Context, ns Namespace, city string, at time.Time, data []byte) error
+	// Ping reports whether the store is reachable (always nil for in-process stores).
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Tiered is a two-level cache: a fast local tier (usually Memory) in front of
+// a shared remote tier (usually Redis).
+//
+// Get checks the local tier first, then the remote one, and back-fills the local
+// tier on a remote hit, so hot keys skip the network round trip. Set writes both.
+type Tiered struct {
+	local  Backend
+	remote Backend
+}
+
+// NewTiered creates a tiered cache from a local and a remote backend.
+func NewTiered(local, remote Backend) *Tiered {
+	return &Tiered{local: local, remote: remote}
+}
+
+// Get returns a fresh local entry if there is one; otherwise it asks the remote
+// tier, which another instance may have refreshed in the meantime.
+// If the remote tier fails, a stale local entry is still returned.
+func (t *Tiered) Get(ctx context.Context, ns Namespace, city string, at time.Time) (*Entry, error) {
+	local, _ := t.local.Get(ctx, ns, city, at)
+	if local != nil && !local.Stale {
+		return local, nil
+	}
+
+	remote, err := t.remote.Get(ctx, ns, city, at)
+	if err != nil {
+		if local != nil {
+			return local, nil
+		}
+		return nil, err
+	}
+	if remote == nil || (local != nil && local.StoredAt.After(remote.StoredAt)) {
+		return local, nil
+	}
+
+	// Back-fill the local tier, keeping the original fetch time so the entry
+	// goes stale locally at the same moment it does in the remote tier
+	t.local.Set(ctx, ns, city, remote.StoredAt, remote.Data)
+	return remote, nil
+}
+
+// Set stores data in both tiers. The local tier is always written, so a
+// remote failure doesn't lose the value for this instance.
+func (t *Tiered) Set(ctx context.Context, ns Namespace, city string, at time.Time, data []byte) error {
+	t.local.Set(ctx, ns, city, at, data)
+	return t.remote.Set(ctx, ns, city, at, data)
+}
+
+// Ping checks both tiers. The remote one is what can actually be down.
+func (t *Tiered) Ping(ctx context.Context) error {
+	if err := t.local.Ping(ctx); err != nil {
+		return err
+	}
+	return t.remote.Ping(ctx)
+}
+
+// Close closes both tiers.
+func (t *Tiered) Close() error {
+	localErr := t.local.Close()
+	if err := t.remote.Close(); err != nil {
+		return err
+	}
+	return localErr
+}
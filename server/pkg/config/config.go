@@ -0,0 +1,537 @@
+// Package config holds every setting the server reads at startup.
+//
+// Settings come from four layers, each overriding the one before:
+//
+//  1. built-in defaults (Default)
+//  2. a TOML config file (-config, or env CONFIG_FILE)
+//  3. environment variables (CACHE_BACKEND, REDIS_ADDR, ...)
+//  4. command-line flags (-listen, -cache, ...)
+//
+// Each setting's file key, environment variable and flag are declared once,
+// as struct tags on Config.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"weather-cli/server/pkg/cache"
+	"weather-cli/server/pkg/logging"
+	"weather-cli/server/pkg/tracing"
+	"weather-cli/server/pkg/weather"
+)
+
+// Config is the server's configuration. Field tags:
+//
+//	toml: key within its section of the config file
+//	env:  environment variable
+//	flag: command-line flag
+//	help: one-line description, shown by -help and -print-config
+//
+// Durations are written like "15s" or "2h"; a bare number means seconds.
+type Config struct {
+	Server   Server   `toml:"server"`
+	Cache    Cache    `toml:"cache"`
+	Redis    Redis    `toml:"redis"`
+	Provider Provider `toml:"provider"`
+	Data     Data     `toml:"data"`
+	Log      Log      `toml:"log"`
+	Tracing  Tracing  `toml:"tracing"`
+}
+
+// Server is the HTTP server itself.
+type Server struct {
+	Listen          string        `toml:"listen" env:"LISTEN_ADDR" flag:"listen" help:"address to listen on"`
+	HandlerTimeout  time.Duration `toml:"handler_timeout" env:"HANDLER_TIMEOUT" flag:"handler-timeout" help:"deadline for each request, upstream calls included"`
+	ShutdownTimeout time.Duration `toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" help:"how long in-flight requests get to finish on SIGINT/SIGTERM (keep above handler_timeout)"`
+	BatchWorkers    int           `toml:"batch_workers" env:"WEATHER_BATCH_WORKERS" flag:"batch-workers" help:"locations a batch request fetches at once"`
+}
+
+// Cache is the weather cache: its backend, keys and TTLs.
+type Cache struct {
+	Backend          string  `toml:"backend" env:"CACHE_BACKEND" flag:"cache" help:"memory, redis, tiered or none"`
+	MemoryMaxEntries int     `toml:"memory_max_entries" env:"CACHE_MEMORY_MAX_ENTRIES" flag:"cache-memory-max-entries" help:"in-memory cache entry limit (0 = no limit)"`
+	MemoryMaxBytes   int     `toml:"memory_max_bytes" env:"CACHE_MEMORY_MAX_BYTES" flag:"cache-memory-max-bytes" help:"in-memory cache size limit in bytes (0 = no limit)"`
+	KeyMode          string  `toml:"key_mode" env:"CACHE_KEY_MODE" flag:"cache-key-mode" help:"city, grid or geohash"`
+	GridSize         float64 `toml:"grid_size" env:"CACHE_GRID_SIZE" flag:"cache-grid-size" help:"grid cell size in degrees (grid key mode)"`
+	GeohashPrecision int     `toml:"geohash_precision" env:"CACHE_GEOHASH_PRECISION" flag:"cache-geohash-precision" help:"geohash length (geohash key mode)"`
+	ServeStale       bool    `toml:"serve_stale" env:"CACHE_SERVE_STALE" flag:"cache-serve-stale" help:"serve expired entries while refreshing them"`
+	DistributedLock  bool    `toml:"distributed_lock" env:"CACHE_DISTRIBUTED_LOCK" flag:"cache-distributed-lock" help:"one upstream fetch per miss across all instances (redis only)"`
+
+	CurrentTTL      time.Duration `toml:"current_ttl" env:"CACHE_CURRENT_TTL" flag:"cache-current-ttl" help:"how long current conditions stay fresh"`
+	CurrentStaleTTL time.Duration `toml:"current_stale_ttl" env:"CACHE_CURRENT_STALE_TTL" flag:"cache-current-stale-ttl" help:"how long current conditions are kept to serve stale"`
+	HourlyTTL       time.Duration `toml:"hourly_ttl" env:"CACHE_HOURLY_TTL" flag:"cache-hourly-ttl" help:"how long hourly forecasts stay fresh"`
+	HourlyStaleTTL  time.Duration `toml:"hourly_stale_ttl" env:"CACHE_HOURLY_STALE_TTL" flag:"cache-hourly-stale-ttl" help:"how long hourly forecasts are kept to serve stale"`
+	DailyTTL        time.Duration `toml:"daily_ttl" env:"CACHE_DAILY_TTL" flag:"cache-daily-ttl" help:"how long daily forecasts stay fresh"`
+	DailyStaleTTL   time.Duration `toml:"daily_stale_ttl" env:"CACHE_DAILY_STALE_TTL" flag:"cache-daily-stale-ttl" help:"how long daily forecasts are kept to serve stale"`
+}
+
+// Redis is the Redis connection used by the redis and tiered cache backends.
+// The password has no flag, so it never shows up in a process listing.
+type Redis struct {
+	Addr     string `toml:"addr" env:"REDIS_ADDR" flag:"redis-addr" help:"Redis host:port"`
+	Password string `toml:"password" env:"REDIS_PASSWORD" secret:"true" help:"Redis password (empty for none)"`
+	DB       int    `toml:"db" env:"REDIS_DB" flag:"redis-db" help:"Redis database number"`
+}
+
+// Provider is the upstream weather source and how failures are handled.
+type Provider struct {
+	Name             string        `toml:"name" env:"WEATHER_PROVIDER" flag:"provider" help:"openmeteo, or fixture for recorded offline responses"`
+	FixtureDir       string        `toml:"fixture_dir" env:"WEATHER_FIXTURE_DIR" flag:"fixture-dir" help:"directory of recorded responses (fixture provider)"`
+	SecondaryURL     string        `toml:"secondary_url" env:"WEATHER_SECONDARY_URL" flag:"secondary-url" help:"Open-Meteo compatible mirror to fail over to (empty for none)"`
+	HTTPTimeout      time.Duration `toml:"http_timeout" env:"WEATHER_HTTP_TIMEOUT" flag:"http-timeout" help:"timeout of one upstream HTTP call"`
+	AttemptTimeout   time.Duration `toml:"attempt_timeout" env:"WEATHER_ATTEMPT_TIMEOUT" flag:"attempt-timeout" help:"time one provider gets before failing over to the next"`
+	BreakerThreshold int           `toml:"breaker_threshold" env:"WEATHER_BREAKER_THRESHOLD" flag:"breaker-threshold" help:"consecutive failures that open a provider's circuit breaker"`
+	BreakerCooldown  time.Duration `toml:"breaker_cooldown" env:"WEATHER_BREAKER_COOLDOWN" flag:"breaker-cooldown" help:"how long an open breaker waits before trying again"`
+}
+
+// Data is the data files. Empty paths use the copies built into the binary.
+type Data struct {
+	CitiesFile       string        `toml:"cities_file" env:"CITIES_FILE" flag:"cities" help:"gazetteer JSON to use instead of the built-in city list"`
+	WeatherCodesFile string        `toml:"weather_codes_file" env:"WEATHER_CODES_FILE" flag:"weather-codes" help:"weather code descriptions JSON to use instead of the built-in table"`
+	ReloadInterval   time.Duration `toml:"reload_interval" env:"GAZETTEER_RELOAD_INTERVAL" flag:"reload-interval" help:"how often cities_file is checked for edits (0 disables)"`
+}
+
+// Log is the server's log output.
+type Log struct {
+	Level  string `toml:"level" env:"LOG_LEVEL" flag:"log-level" help:"debug, info, warn or error"`
+	Format string `toml:"format" env:"LOG_FORMAT" flag:"log-format" help:"json (one object per line) or text"`
+}
+
+// Tracing is where request traces go. Spans are always created and
+// traceparent always propagated; the exporter only decides what is recorded.
+type Tracing struct {
+	Exporter     string  `toml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" help:"none, stdout, file or otlp"`
+	File         string  `toml:"file" env:"TRACING_FILE" flag:"tracing-file" help:"file spans are appended to, one JSON object per line (file exporter)"`
+	OTLPEndpoint string  `toml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" flag:"tracing-otlp-endpoint" help:"OTLP/HTTP traces URL of a collector (otlp exporter)"`
+	ServiceName  string  `toml:"service_name" env:"OTEL_SERVICE_NAME" flag:"tracing-service-name" help:"service.name reported with every span"`
+	SampleRatio  float64 `toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" help:"share of new traces recorded, 0 to 1 (callers' traceparent decisions are kept)"`
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	currentTTL, currentStaleTTL := cache.TTLs(cache.NamespaceCurrent)
+	hourlyTTL, hourlyStaleTTL := cache.TTLs(cache.NamespaceHourly)
+	dailyTTL, dailyStaleTTL := cache.TTLs(cache.NamespaceDaily)
+
+	return Config{
+		Server: Server{
+			Listen:          ":8080",
+			HandlerTimeout:  15 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+			BatchWorkers:    weather.DefaultBatchWorkers,
+		},
+		Cache: Cache{
+			Backend:          "redis",
+			MemoryMaxEntries: cache.DefaultMemoryMaxEntries,
+			MemoryMaxBytes:   cache.DefaultMemoryMaxBytes,
+			KeyMode:          "city",
+			GridSize:         cache.DefaultGridSize,
+			GeohashPrecision: cache.DefaultGeohashPrecision,
+			ServeStale:       true,
+			CurrentTTL:       currentTTL,
+			CurrentStaleTTL:  currentStaleTTL,
+			HourlyTTL:        hourlyTTL,
+			HourlyStaleTTL:   hourlyStaleTTL,
+			DailyTTL:         dailyTTL,
+			DailyStaleTTL:    dailyStaleTTL,
+		},
+		Redis: Redis{
+			Addr: "localhost:6379",
+		},
+		Provider: Provider{
+			Name:             "openmeteo",
+			FixtureDir:       "server/fixtures",
+			HTTPTimeout:      weather.DefaultHTTPTimeout,
+			AttemptTimeout:   weather.DefaultAttemptTimeout,
+			BreakerThreshold: weather.DefaultBreakerThreshold,
+			BreakerCooldown:  weather.DefaultBreakerCooldown,
+		},
+		Data: Data{
+			ReloadInterval: 30 * time.Second,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+		Tracing: Tracing{
+			Exporter:     "none",
+			File:         "traces.jsonl",
+			OTLPEndpoint: tracing.DefaultOTLPEndpoint,
+			ServiceName:  "weather-server",
+			SampleRatio:  1,
+		},
+	}
+}
+
+// setting is one configurable field of a Config, with its tags.
+type setting struct {
+	key    string // "cache.backend"
+	env    string
+	flag   string
+	help   string
+	secret bool
+	value  reflect.Value
+}
+
+// settings lists every setting of c in declaration order, addressable so
+// they can be set in place.
+func (c *Config) settings() []setting {
+	var out []setting
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i).Tag.Get("toml")
+		sv := root.Field(i)
+		for j := 0; j < sv.NumField(); j++ {
+			f := sv.Type().Field(j)
+			out = append(out, setting{
+				key:    section + "." + f.Tag.Get("toml"),
+				env:    f.Tag.Get("env"),
+				flag:   f.Tag.Get("flag"),
+				help:   f.Tag.Get("help"),
+				secret: f.Tag.Get("secret") == "true",
+				value:  sv.Field(j),
+			})
+		}
+	}
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses s into the setting's field.
+func (s setting) set(raw string) error {
+	v := s.value
+	switch {
+	case v.Type() == durationType:
+		d, err := parseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// format renders the setting's current value the way set reads it back.
+func (s setting) format() string {
+	v := s.value
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case v.Kind() == reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// quoted reports whether the setting is written as a TOML string.
+// Durations are too ("15s"), though a bare number of seconds is accepted.
+func (s setting) quoted() bool {
+	return s.value.Kind() == reflect.String || s.value.Type() == durationType
+}
+
+// parseDuration reads "15s", "2h30m", or a bare number of seconds ("30"),
+// as GAZETTEER_RELOAD_INTERVAL has always been given.
+func parseDuration(raw string) (time.Duration, error) {
+	if n, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration (like 15s or 2h)", raw)
+	}
+	return d, nil
+}
+
+// Flags are the configuration's command-line flags. Values given on the
+// command line are held until Load, so they can be applied last.
+type Flags struct {
+	// File is the config file to read (-config).
+	File string
+	// Print asks for the effective configuration to be printed (-print-config).
+	Print bool
+
+	values map[string]*flagValue
+}
+
+// flagValue records a flag's value without touching any Config.
+type flagValue struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *flagValue) Set(s string) error {
+	f.value, f.set = s, true
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be given as plain -cache-serve-stale.
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
+
+// RegisterFlags defines -config, -print-config and a flag for every setting
+// on fs. Call Load on the result after fs is parsed.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{values: make(map[string]*flagValue)}
+	fs.StringVar(&f.File, "config", os.Getenv("CONFIG_FILE"), "TOML config file to read (env CONFIG_FILE)")
+	fs.BoolVar(&f.Print, "print-config", false, "print the effective configuration as TOML and exit")
+
+	def := Default()
+	for _, s := range def.settings() {
+		if s.flag == "" {
+			continue
+		}
+		v := &flagValue{value: s.format(), isBool: s.value.Kind() == reflect.Bool}
+		f.values[s.key] = v
+		fs.Var(v, s.flag, fmt.Sprintf("%s (env %s)", s.help, s.env))
+	}
+	return f
+}
+
+// Load builds the configuration: defaults, then the config file (if any),
+// then environment variables, then flags given on the command line. The
+// result is validated; every problem found is reported at once.
+func (f *Flags) Load() (Config, error) {
+	c := Default()
+	settings := c.settings()
+
+	if f.File != "" {
+		if err := c.loadFile(f.File, settings); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		// Empty variables count as unset, as they always have
+		raw := os.Getenv(s.env)
+		if raw == "" {
+			continue
+		}
+		if err := s.set(raw); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", s.env, err)
+		}
+	}
+
+	for _, s := range settings {
+		v := f.values[s.key]
+		if v == nil || !v.set {
+			continue
+		}
+		if err := s.set(v.value); err != nil {
+			return Config{}, fmt.Errorf("-%s: %w", s.flag, err)
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// loadFile applies a TOML config file. Unknown keys are errors, so a typo
+// doesn't silently leave a default in place.
+func (c *Config) loadFile(path string, settings []setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	values, err := parseTOML(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	// Sorted by line so the first problem in the file is the one reported
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return values[keys[i]].line < values[keys[j]].line })
+
+	for _, k := range keys {
+		v := values[k]
+		s, ok := byKey[k]
+		if !ok {
+			return fmt.Errorf("%s:%d: unknown setting %s", path, v.line, k)
+		}
+		if v.quoted && !s.quoted() {
+			return fmt.Errorf("%s:%d: %s takes a bare value, not a string", path, v.line, k)
+		}
+		if !v.quoted && s.value.Kind() == reflect.String {
+			return fmt.Errorf("%s:%d: %s must be a quoted string", path, v.line, k)
+		}
+		if err := s.set(v.raw); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, v.line, k, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the settings make sense together.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Listen != "", "server.listen must not be empty")
+	check(c.Server.HandlerTimeout > 0, "server.handler_timeout must be positive, got %v", c.Server.HandlerTimeout)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive, got %v", c.Server.ShutdownTimeout)
+	check(c.Server.BatchWorkers >= 1, "server.batch_workers must be at least 1, got %d", c.Server.BatchWorkers)
+
+	switch c.Cache.Backend {
+	case "memory", "redis", "tiered", "none":
+	default:
+		errs = append(errs, fmt.Errorf("cache.backend %q is not memory, redis, tiered or none", c.Cache.Backend))
+	}
+	check(c.Cache.MemoryMaxEntries >= 0, "cache.memory_max_entries must not be negative")
+	check(c.Cache.MemoryMaxBytes >= 0, "cache.memory_max_bytes must not be negative")
+	if _, err := cache.NewKeyer(c.Cache.KeyMode, c.Cache.GridSize, c.Cache.GeohashPrecision); err != nil {
+		errs = append(errs, fmt.Errorf("cache key settings: %w", err))
+	}
+	ttls := []struct {
+		name         string
+		fresh, stale time.Duration
+	}{
+		{"current", c.Cache.CurrentTTL, c.Cache.CurrentStaleTTL},
+		{"hourly", c.Cache.HourlyTTL, c.Cache.HourlyStaleTTL},
+		{"daily", c.Cache.DailyTTL, c.Cache.DailyStaleTTL},
+	}
+	for _, t := range ttls {
+		check(t.fresh > 0, "cache.%s_ttl must be positive, got %v", t.name, t.fresh)
+		check(t.stale >= t.fresh, "cache.%s_stale_ttl (%v) must be at least cache.%s_ttl (%v)", t.name, t.stale, t.name, t.fresh)
+	}
+
+	if c.Cache.Backend == "redis" || c.Cache.Backend == "tiered" {
+		check(c.Redis.Addr != "", "redis.addr must be set for the %s cache backend", c.Cache.Backend)
+	}
+	check(c.Redis.DB >= 0, "redis.db must not be negative, got %d", c.Redis.DB)
+
+	switch c.Provider.Name {
+	case "openmeteo":
+	case "fixture":
+		if c.Provider.FixtureDir == "" {
+			errs = append(errs, fmt.Errorf("provider.fixture_dir must be set for the fixture provider"))
+		} else if fi, err := os.Stat(c.Provider.FixtureDir); err != nil || !fi.IsDir() {
+			// Relative paths are relative to the working directory, not the
+			// config file, so the default only works from the repository root
+			wd, _ := os.Getwd()
+			errs = append(errs, fmt.Errorf("provider.fixture_dir %q is not a directory (relative paths are resolved from the working directory, %s)", c.Provider.FixtureDir, wd))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("provider.name %q is not openmeteo or fixture", c.Provider.Name))
+	}
+	if c.Provider.SecondaryURL != "" {
+		u, err := url.Parse(c.Provider.SecondaryURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"provider.secondary_url %q is not an http(s) URL", c.Provider.SecondaryURL)
+	}
+	check(c.Provider.HTTPTimeout > 0, "provider.http_timeout must be positive, got %v", c.Provider.HTTPTimeout)
+	check(c.Provider.AttemptTimeout > 0, "provider.attempt_timeout must be positive, got %v", c.Provider.AttemptTimeout)
+	check(c.Provider.BreakerThreshold >= 1, "provider.breaker_threshold must be at least 1, got %d", c.Provider.BreakerThreshold)
+	check(c.Provider.BreakerCooldown > 0, "provider.breaker_cooldown must be positive, got %v", c.Provider.BreakerCooldown)
+
+	check(c.Data.ReloadInterval >= 0, "data.reload_interval must not be negative, got %v", c.Data.ReloadInterval)
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	check(c.Log.Format == logging.FormatJSON || c.Log.Format == logging.FormatText,
+		"log.format %q is not %s or %s", c.Log.Format, logging.FormatJSON, logging.FormatText)
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "file":
+		check(c.Tracing.File != "", "tracing.file must be set for the file exporter")
+	case "otlp":
+		u, err := url.Parse(c.Tracing.OTLPEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.otlp_endpoint %q is not an http(s) URL", c.Tracing.OTLPEndpoint)
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q is not none, stdout, file or otlp", c.Tracing.Exporter))
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1,
+		"tracing.sample_ratio must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+
+	return errors.Join(errs...)
+}
+
+// WriteTOML writes c as a config file that Load reads back to the same
+// settings. Secrets are left out: a set password shows up as a comment.
+func (c Config) WriteTOML(w io.Writer) error {
+	var b strings.Builder
+	section := ""
+	for _, s := range c.settings() {
+		name, key, _ := strings.Cut(s.key, ".")
+		if name != section {
+			if section != "" {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "[%s]\n", name)
+			section = name
+		}
+
+		fmt.Fprintf(&b, "# %s (env %s)\n", s.help, s.env)
+		value := s.format()
+		switch {
+		case s.secret && value != "":
+			fmt.Fprintf(&b, "# %s is set, not shown\n", key)
+		case s.quoted():
+			fmt.Fprintf(&b, "%s = %s\n", key, strconv.Quote(value))
+		default:
+			fmt.Fprintf(&b, "%s = %s\n", key, value)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
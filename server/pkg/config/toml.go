@@ -0,0 +1,239 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlValue is one `key = value` from a config file, still as text.
+// quoted tells strings ("15s") apart from bare numbers and booleans (15, true),
+// so a setting can insist on the right kind.
+type tomlValue struct {
+	raw    string
+	quoted bool
+	line   int
+}
+
+// parseTOML reads the small subset of TOML a config file needs:
+//
+//	# comments
+//	[section]
+//	key = "basic string"   # or 'literal string'
+//	key = 42               # integers and floats, 1_000 style underscores allowed
+//	key = true
+//
+// Keys are returned as "section.key". Arrays, inline tables, multi-line
+// strings, dates, nested or repeated sections, and numbers other than plain
+// decimals are rejected rather than guessed at.
+func parseTOML(data []byte) (map[string]tomlValue, error) {
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("not valid UTF-8")
+	}
+
+	values := make(map[string]tomlValue)
+	sections := make(map[string]bool)
+	section := ""
+
+	for i, line := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			name, rest, ok := strings.Cut(line[1:], "]")
+			if strings.HasPrefix(name, "[") {
+				return nil, fmt.Errorf("line %d: arrays of tables are not supported", n)
+			}
+			if !ok || !isComment(rest) {
+				return nil, fmt.Errorf("line %d: malformed section header %q", n, line)
+			}
+			name = strings.TrimSpace(name)
+			if !isBareKey(name) {
+				return nil, fmt.Errorf("line %d: unsupported section name %q", n, name)
+			}
+			if sections[name] {
+				return nil, fmt.Errorf("line %d: section [%s] defined twice", n, name)
+			}
+			sections[name] = true
+			section = name
+			continue
+		}
+
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", n, line)
+		}
+		key = strings.TrimSpace(key)
+		if !isBareKey(key) {
+			return nil, fmt.Errorf("line %d: unsupported key %q", n, key)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: %s set twice", n, key)
+		}
+
+		v, err := parseTOMLValue(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		v.line = n
+		values[key] = v
+	}
+
+	return values, nil
+}
+
+// parseTOMLValue parses the right-hand side of `key = value`, trailing comment included.
+func parseTOMLValue(s string) (tomlValue, error) {
+	switch {
+	case s == "" || strings.HasPrefix(s, "#"):
+		return tomlValue{}, fmt.Errorf("missing value")
+
+	case strings.HasPrefix(s, `"""`), strings.HasPrefix(s, "'''"):
+		return tomlValue{}, fmt.Errorf("multi-line strings are not supported")
+
+	case s[0] == '"':
+		// Find the closing quote, skipping escaped ones
+		end := -1
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == '"' {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return tomlValue{}, fmt.Errorf("unterminated string")
+		}
+		if !isComment(s[end+1:]) {
+			return tomlValue{}, fmt.Errorf("unexpected text after string: %q", s[end+1:])
+		}
+		str, err := unescapeTOML(s[1:end])
+		if err != nil {
+			return tomlValue{}, fmt.Errorf("bad string %s: %w", s[:end+1], err)
+		}
+		return tomlValue{raw: str, quoted: true}, nil
+
+	case s[0] == '\'':
+		// Literal strings have no escapes at all
+		str, rest, ok := strings.Cut(s[1:], "'")
+		if !ok {
+			return tomlValue{}, fmt.Errorf("unterminated string")
+		}
+		if !isComment(rest) {
+			return tomlValue{}, fmt.Errorf("unexpected text after string: %q", rest)
+		}
+		if i := strings.IndexFunc(str, isTOMLControl); i >= 0 {
+			return tomlValue{}, fmt.Errorf("bad string: control character %U", str[i])
+		}
+		return tomlValue{raw: str, quoted: true}, nil
+
+	case s[0] == '[', s[0] == '{':
+		return tomlValue{}, fmt.Errorf("arrays and inline tables are not supported")
+	}
+
+	// Bare value: a number or boolean, up to an optional comment
+	bare, _, _ := strings.Cut(s, "#")
+	bare = strings.TrimSpace(bare)
+	if strings.ContainsAny(bare, " \t") {
+		return tomlValue{}, fmt.Errorf("unexpected value %q (strings must be quoted)", bare)
+	}
+	if bare != "true" && bare != "false" && !tomlNumber.MatchString(bare) {
+		return tomlValue{}, fmt.Errorf("unexpected value %q (want a decimal number, true, false or a quoted string)", bare)
+	}
+	return tomlValue{raw: strings.ReplaceAll(bare, "_", "")}, nil
+}
+
+// tomlNumber matches TOML decimal integers and floats: no leading zeros, and
+// underscores only between two digits. Hex, octal, binary, inf and nan aren't
+// needed by any setting, so they don't match.
+var tomlNumber = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+
+// unescapeTOML decodes the inside of a TOML basic string. Only TOML's own
+// escapes are accepted (\b \t \n \f \r \" \\ \uXXXX \UXXXXXXXX): Go-only
+// ones like \x41, \a or \101 are errors, as are unescaped control characters.
+func unescapeTOML(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			if isTOMLControl(rune(c)) {
+				return "", fmt.Errorf("control character %U must be escaped", c)
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		switch s[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(s[i])
+		case 'u', 'U':
+			digits := 4
+			if s[i] == 'U' {
+				digits = 8
+			}
+			hex := s[i+1 : min(i+1+digits, len(s))]
+			code, err := strconv.ParseUint(hex, 16, 32)
+			if len(hex) != digits || err != nil {
+				return "", fmt.Errorf("\\%c needs %d hex digits", s[i], digits)
+			}
+			if !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("\\%c%s is not a Unicode scalar value", s[i], hex)
+			}
+			b.WriteRune(rune(code))
+			i += digits
+		default:
+			return "", fmt.Errorf("invalid escape \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// isTOMLControl reports whether r is a control character TOML strings may
+// not contain as-is (tab is allowed).
+func isTOMLControl(r rune) bool {
+	return r < 0x20 && r != '\t' || r == 0x7f
+}
+
+// isComment reports whether s is empty or just a trailing comment.
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || strings.HasPrefix(s, "#")
+}
+
+// isBareKey reports whether s is a TOML bare key: letters, digits, _ and -.
+func isBareKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]tomlValue
+	}{
+		{
+			name: "sections",
+			in:   "listen = \":8080\"\n\n[cache]\nbackend = \"redis\"\n\n[provider]\ntimeout = 10\n",
+			want: map[string]tomlValue{
+				"listen":           {raw: ":8080", quoted: true, line: 1},
+				"cache.backend":    {raw: "redis", quoted: true, line: 4},
+				"provider.timeout": {raw: "10", line: 7},
+			},
+		},
+		{
+			name: "comments and blank lines",
+			in:   "# top\n\n  # indented\n[cache] # trailing\nttl = \"15m\" # after a string\nsize = 100 # after a number\n",
+			want: map[string]tomlValue{
+				"cache.ttl":  {raw: "15m", quoted: true, line: 5},
+				"cache.size": {raw: "100", line: 6},
+			},
+		},
+		{
+			name: "hash inside strings",
+			in:   "a = \"#not a comment\" # but this is\nb = 'x # y'\n",
+			want: map[string]tomlValue{
+				"a": {raw: "#not a comment", quoted: true, line: 1},
+				"b": {raw: "x # y", quoted: true, line: 2},
+			},
+		},
+		{
+			name: "escapes",
+			in:   `a = "tab\there \"quoted\" back\\slash"` + "\n" + `b = "\u00e9\U0001F327\n"` + "\n" + `c = 'C:\no\escapes'`,
+			want: map[string]tomlValue{
+				"a": {raw: "tab\there \"quoted\" back\\slash", quoted: true, line: 1},
+				"b": {raw: "é🌧\n", quoted: true, line: 2},
+				"c": {raw: `C:\no\escapes`, quoted: true, line: 3},
+			},
+		},
+		{
+			name: "numbers and booleans",
+			in:   "a = 1_000_000\nb = -0.5\nc = +1e3\nd = 6.02E2_3\ne = 0\nf = true\ng = false\n",
+			want: map[string]tomlValue{
+				"a": {raw: "1000000", line: 1},
+				"b": {raw: "-0.5", line: 2},
+				"c": {raw: "+1e3", line: 3},
+				"d": {raw: "6.02E23", line: 4},
+				"e": {raw: "0", line: 5},
+				"f": {raw: "true", line: 6},
+				"g": {raw: "false", line: 7},
+			},
+		},
+		{
+			name: "CRLF line endings",
+			in:   "[cache]\r\nttl = \"1m\"\r\n",
+			want: map[string]tomlValue{
+				"cache.ttl": {raw: "1m", quoted: true, line: 2},
+			},
+		},
+		{
+			name: "empty strings",
+			in:   "a = \"\"\nb = ''\n",
+			want: map[string]tomlValue{
+				"a": {quoted: true, line: 1},
+				"b": {quoted: true, line: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOML =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // substring of the error
+	}{
+		{"duplicate key", "a = 1\na = 2", "line 2: a set twice"},
+		{"duplicate key in section", "[s]\na = 1\nb = 2\na = 3", "line 4: s.a set twice"},
+		{"duplicate section", "[s]\na = 1\n[t]\n[s]", "line 4: section [s] defined twice"},
+		{"dotted section", "[cache.redis]", "unsupported section name"},
+		{"dotted key", "cache.ttl = 1", "unsupported key"},
+		{"quoted key", `"ttl" = 1`, "unsupported key"},
+		{"array of tables", "[[servers]]", "arrays of tables"},
+		{"unclosed section", "[cache", "malformed section header"},
+		{"text after section", "[cache] ttl = 1", "malformed section header"},
+		{"no equals", "listen", "expected key = value"},
+		{"missing value", "a =", "missing value"},
+		{"only a comment", "a = # nothing", "missing value"},
+		{"array", "a = [1, 2]", "arrays and inline tables"},
+		{"inline table", "a = {b = 1}", "arrays and inline tables"},
+		{"multi-line basic string", `a = """x"""`, "multi-line strings"},
+		{"multi-line literal string", "a = '''x'''", "multi-line strings"},
+		{"unterminated basic string", `a = "x`, "unterminated string"},
+		{"escaped closing quote", `a = "x\"`, "unterminated string"},
+		{"unterminated literal string", "a = 'x", "unterminated string"},
+		{"text after basic string", `a = "x" y`, "unexpected text after string"},
+		{"text after literal string", "a = 'x' y", "unexpected text after string"},
+		{"bare string", "a = hello", "unexpected value"},
+		{"bare words", "a = hello world", "strings must be quoted"},
+
+		// Go escapes TOML doesn't have
+		{"hex escape", `a = "\x41"`, `invalid escape \x`},
+		{"bell escape", `a = "\a"`, `invalid escape \a`},
+		{"vertical tab escape", `a = "\v"`, `invalid escape \v`},
+		{"octal escape", `a = "\101"`, `invalid escape \1`},
+		{"single quote escape", `a = "\'"`, `invalid escape \'`},
+		{"short unicode escape", `a = "\u00e"`, `\u needs 4 hex digits`},
+		{"bad unicode digits", `a = "\u00eg"`, `\u needs 4 hex digits`},
+		{"short long unicode escape", `a = "\U0001F32"`, `\U needs 8 hex digits`},
+		{"surrogate", `a = "\ud800"`, "not a Unicode scalar value"},
+		{"beyond unicode", `a = "\U00110000"`, "not a Unicode scalar value"},
+		{"raw control character", "a = \"x\x01y\"", "control character U+0001"},
+		{"raw control character in literal", "a = 'x\x7fy'", "control character U+007F"},
+		{"invalid UTF-8", "a = \"\xff\"", "not valid UTF-8"},
+
+		// Numbers TOML allows but no setting needs, and ones it forbids
+		{"leading underscore", "a = _1", "unexpected value"},
+		{"trailing underscore", "a = 1_", "unexpected value"},
+		{"double underscore", "a = 1__000", "unexpected value"},
+		{"underscore by the point", "a = 1_.5", "unexpected value"},
+		{"underscore after the point", "a = 1._5", "unexpected value"},
+		{"leading zero", "a = 015", "unexpected value"},
+		{"no digit before the point", "a = .5", "unexpected value"},
+		{"no digit after the point", "a = 5.", "unexpected value"},
+		{"hex number", "a = 0x1F", "unexpected value"},
+		{"infinity", "a = inf", "unexpected value"},
+		{"capitalised boolean", "a = True", "unexpected value"},
+		{"date", "a = 2024-01-01", "unexpected value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML([]byte(tt.in))
+			if err == nil {
+				t.Fatalf("parseTOML(%q) = %v, want an error", tt.in, got)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseTOML(%q) error %q, want it to mention %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
+
+// The shipped example must stay loadable.
+func TestParseTOMLExample(t *testing.T) {
+	data, err := os.ReadFile("../../config.example.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseTOML(data); err != nil {
+		t.Errorf("config.example.toml: %v", err)
+	}
+}
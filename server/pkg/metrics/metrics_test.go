@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Every metric registers in the one process-wide registry, so each test uses
+// its own metric names.
+
+func TestCounterVecText(t *testing.T) {
+	c := NewCounterVec("test_requests_total", "Requests served.", "route", "status")
+	c.Inc("/weather", "200")
+	c.Inc("/weather", "200")
+	c.Add(0.5, "/forecast", "500")
+	c.Inc(`C:\path`, "say \"hi\"\nbye")
+
+	want := `# HELP test_requests_total Requests served.
+# TYPE test_requests_total counter
+test_requests_total{route="/forecast",status="500"} 0.5
+test_requests_total{route="/weather",status="200"} 2
+test_requests_total{route="C:\\path",status="say \"hi\"\nbye"} 1
+`
+	var b strings.Builder
+	c.write(&b)
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestCounterWithoutLabels(t *testing.T) {
+	c := NewCounterVec("test_reloads_total", "Line one\nand a back\\slash.")
+	c.Add(3)
+
+	want := `# HELP test_reloads_total Line one\nand a back\\slash.
+# TYPE test_reloads_total counter
+test_reloads_total 3
+`
+	var b strings.Builder
+	c.write(&b)
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestHistogramVecText(t *testing.T) {
+	// Bounds are sorted, whatever order they're given in
+	h := NewHistogramVec("test_duration_seconds", "Time taken.", []float64{1, 0.1, 0.5}, "route")
+	for _, v := range []float64{0.05, 0.1, 0.3, 2, 7} {
+		h.Observe(v, "/weather")
+	}
+	h.Observe(0.5, `a"b`)
+
+	// Buckets are cumulative and a value on a bound counts in that bucket;
+	// +Inf always equals _count
+	want := `# HELP test_duration_seconds Time taken.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/weather",le="0.1"} 2
+test_duration_seconds_bucket{route="/weather",le="0.5"} 3
+test_duration_seconds_bucket{route="/weather",le="1"} 3
+test_duration_seconds_bucket{route="/weather",le="+Inf"} 5
+test_duration_seconds_sum{route="/weather"} 9.45
+test_duration_seconds_count{route="/weather"} 5
+test_duration_seconds_bucket{route="a\"b",le="0.1"} 0
+test_duration_seconds_bucket{route="a\"b",le="0.5"} 1
+test_duration_seconds_bucket{route="a\"b",le="1"} 1
+test_duration_seconds_bucket{route="a\"b",le="+Inf"} 1
+test_duration_seconds_sum{route="a\"b"} 0.5
+test_duration_seconds_count{route="a\"b"} 1
+`
+	var b strings.Builder
+	h.write(&b)
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	h := NewHistogramVec("test_size_bytes", "Sizes.", []float64{100})
+	h.Observe(1e6)
+
+	want := `# HELP test_size_bytes Sizes.
+# TYPE test_size_bytes histogram
+test_size_bytes_bucket{le="100"} 0
+test_size_bytes_bucket{le="+Inf"} 1
+test_size_bytes_sum 1e+06
+test_size_bytes_count 1
+`
+	var b strings.Builder
+	h.write(&b)
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestGaugeFuncText(t *testing.T) {
+	v := 42.0
+	g := NewGaugeFunc("test_entries", "Entries held.", func() float64 { return v })
+
+	var b strings.Builder
+	g.write(&b)
+	v = math.Inf(1)
+	g.write(&b)
+
+	// Read at scrape time, not when created
+	want := `# HELP test_entries Entries held.
+# TYPE test_entries gauge
+test_entries 42
+# HELP test_entries Entries held.
+# TYPE test_entries gauge
+test_entries +Inf
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for v, want := range map[float64]string{
+		0:            "0",
+		1.5:          "1.5",
+		-2:           "-2",
+		0.005:        "0.005",
+		1e21:         "1e+21",
+		math.Inf(1):  "+Inf",
+		math.Inf(-1): "-Inf",
+		math.NaN():   "NaN",
+	} {
+		if got := formatFloat(v); got != want {
+			t.Errorf("formatFloat(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	NewGaugeFunc("test_handler_b", "B.", func() float64 { return 2 })
+	NewGaugeFunc("test_handler_a", "A.", func() float64 { return 1 })
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	// Metrics come out sorted by name
+	body := rec.Body.String()
+	a, b := strings.Index(body, "test_handler_a 1\n"), strings.Index(body, "test_handler_b 2\n")
+	if a < 0 || b < 0 || a > b {
+		t.Errorf("want test_handler_a then test_handler_b in:\n%s", body)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	c := NewCounterVec("test_misuse_total", "Misuse.", "route")
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"duplicate name", func() { NewCounterVec("test_misuse_total", "Again.") }},
+		{"duplicate name, other kind", func() { NewGaugeFunc("test_misuse_total", "Again.", nil) }},
+		{"too few label values", func() { c.Inc() }},
+		{"too many label values", func() { c.Inc("/a", "/b") }},
+		{"negative add", func() { c.Add(-1, "/a") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+
+	// The panics left the counter untouched
+	var b strings.Builder
+	c.write(&b)
+	if strings.Contains(b.String(), "test_misuse_total{") {
+		t.Errorf("counter has values after failed calls:\n%s", b.String())
+	}
+}
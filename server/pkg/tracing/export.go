@@ -0,0 +1,391 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SpanData is a finished span, as handed to an Exporter.
+type SpanData struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID // zero for a trace's root span
+	Name     string
+	Kind     SpanKind
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	Error    string // empty unless RecordError was called
+}
+
+// Exporter sends finished spans somewhere: a file, a collector.
+// Export is only ever called from one goroutine at a time.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Spans are batched before export, so a request never waits on an exporter:
+// End just queues the span, and a background goroutine sends a batch when
+// it is full or every batchInterval. If the queue fills up (the exporter is
+// slow or down), new spans are dropped rather than blocking requests.
+const (
+	queueSize     = 2048
+	batchSize     = 256
+	batchInterval = time.Second
+)
+
+// processor is the running exporter, if any.
+var processor struct {
+	mu      sync.Mutex
+	current *batcher
+}
+
+type batcher struct {
+	exporter Exporter
+	queue    chan SpanData
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// SetExporter starts exporting sampled spans to e, replacing (and shutting
+// down) any earlier exporter. A nil e turns exporting off, which is the
+// default: spans are still created and propagated, just not recorded.
+func SetExporter(e Exporter) {
+	var b *batcher
+	if e != nil {
+		b = &batcher{
+			exporter: e,
+			queue:    make(chan SpanData, queueSize),
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+		go b.run()
+	}
+
+	processor.mu.Lock()
+	old := processor.current
+	processor.current = b
+	processor.mu.Unlock()
+
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		old.shutdown(ctx)
+	}
+}
+
+// Shutdown exports any queued spans and shuts the exporter down, giving up
+// when ctx is done. Call it before the process exits.
+func Shutdown(ctx context.Context) error {
+	processor.mu.Lock()
+	b := processor.current
+	processor.current = nil
+	processor.mu.Unlock()
+
+	if b == nil {
+		return nil
+	}
+	return b.shutdown(ctx)
+}
+
+// export queues a finished span, or drops it if there is no room.
+func export(s SpanData) {
+	processor.mu.Lock()
+	b := processor.current
+	processor.mu.Unlock()
+
+	if b == nil {
+		return
+	}
+	select {
+	case b.queue <- s:
+	default:
+		b.mu.Lock()
+		b.dropped++
+		b.mu.Unlock()
+	}
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := b.exporter.Export(ctx, batch); err != nil {
+			slog.Warn("span export failed", "spans", len(batch), "err", err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+
+		b.mu.Lock()
+		dropped := b.dropped
+		b.dropped = 0
+		b.mu.Unlock()
+		if dropped > 0 {
+			slog.Warn("spans dropped, export queue full", "spans", dropped)
+		}
+	}
+
+	for {
+		select {
+		case s := <-b.queue:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-b.stop:
+			// Drain whatever was queued before shutdown
+			for {
+				select {
+				case s := <-b.queue:
+					batch = append(batch, s)
+					if len(batch) >= batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *batcher) shutdown(ctx context.Context) error {
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return fmt.Errorf("flushing spans: %w", ctx.Err())
+	}
+	return b.exporter.Shutdown(ctx)
+}
+
+// WriterExporter writes each span as one JSON object per line, for local
+// debugging: point it at stdout or a file and read it with jq.
+type WriterExporter struct {
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewWriterExporter writes spans to w. If w is an io.Closer (an *os.File
+// other than stdout, say), pass close=true to close it on Shutdown.
+func NewWriterExporter(w io.Writer, close bool) *WriterExporter {
+	e := &WriterExporter{w: w}
+	if c, ok := w.(io.Closer); ok && close {
+		e.closer = c
+	}
+	return e
+}
+
+// jsonSpan is how WriterExporter lays out a span.
+type jsonSpan struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_span_id,omitempty"`
+	Name       string         `json:"name"`
+	Kind       string         `json:"kind"`
+	Start      time.Time      `json:"start"`
+	DurationMS float64        `json:"duration_ms"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Export implements Exporter.
+func (e *WriterExporter) Export(ctx context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range spans {
+		js := jsonSpan{
+			TraceID:    s.TraceID.String(),
+			SpanID:     s.SpanID.String(),
+			Name:       s.Name,
+			Kind:       s.Kind.String(),
+			Start:      s.Start,
+			DurationMS: float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+			Error:      s.Error,
+		}
+		if s.ParentID.IsValid() {
+			js.ParentID = s.ParentID.String()
+		}
+		if len(s.Attrs) > 0 {
+			js.Attributes = make(map[string]any, len(s.Attrs))
+			for _, a := range s.Attrs {
+				js.Attributes[a.Key] = a.Value
+			}
+		}
+		if err := enc.Encode(js); err != nil {
+			return err
+		}
+	}
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// Shutdown implements Exporter.
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
+
+// DefaultOTLPEndpoint is where an OpenTelemetry Collector running locally
+// accepts traces over OTLP/HTTP.
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// OTLPExporter posts spans to an OpenTelemetry Collector (or Jaeger, Tempo,
+// anything speaking OTLP/HTTP) using the JSON encoding of OTLP.
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+}
+
+// NewOTLPExporter sends spans to endpoint (the full URL, usually ending in
+// /v1/traces), labelled with serviceName.
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// The OTLP JSON request body. IDs are hex strings and timestamps are
+// nanoseconds since the epoch written as strings, as OTLP/JSON requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Message string `json:"message,omitempty"`
+		Code    int    `json:"code,omitempty"` // 2 = error
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+func otlpAttr(a Attr) otlpKeyValue {
+	var v map[string]any
+	switch x := a.Value.(type) {
+	case string:
+		v = map[string]any{"stringValue": x}
+	case int64:
+		// int64 values are strings in OTLP/JSON
+		v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		v = map[string]any{"doubleValue": x}
+	case bool:
+		v = map[string]any{"boolValue": x}
+	default:
+		v = map[string]any{"stringValue": fmt.Sprint(x)}
+	}
+	return otlpKeyValue{Key: a.Key, Value: v}
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              int(s.Kind),
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if s.ParentID.IsValid() {
+			span.ParentSpanID = s.ParentID.String()
+		}
+		for _, a := range s.Attrs {
+			span.Attributes = append(span.Attributes, otlpAttr(a))
+		}
+		if s.Error != "" {
+			span.Status = otlpStatus{Message: s.Error, Code: 2}
+		}
+		otlpSpans = append(otlpSpans, span)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpAttr(String("service.name", e.serviceName))}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "weather-cli/server"}, Spans: otlpSpans}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting spans to %s: %w", e.endpoint, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return errors.New("collector at " + e.endpoint + " returned " + resp.Status)
+	}
+	return nil
+}
+
+// Shutdown implements Exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,365 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeExporter records what it is sent. If block is set, Export waits for
+// it to close first.
+type fakeExporter struct {
+	block chan struct{}
+
+	mu      sync.Mutex
+	batches [][]SpanData
+	closed  bool
+}
+
+func (e *fakeExporter) Export(ctx context.Context, spans []SpanData) error {
+	if e.block != nil {
+		<-e.block
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batches = append(e.batches, append([]SpanData(nil), spans...))
+	return nil
+}
+
+func (e *fakeExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	return nil
+}
+
+// names returns the exported span names in order, and the batch sizes.
+func (e *fakeExporter) names() ([]string, []int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var names []string
+	var sizes []int
+	for _, b := range e.batches {
+		sizes = append(sizes, len(b))
+		for _, s := range b {
+			names = append(names, s.Name)
+		}
+	}
+	return names, sizes
+}
+
+// withExporter exports to e for the duration of the test.
+func withExporter(t *testing.T, e Exporter) {
+	t.Helper()
+	SetExporter(e)
+	t.Cleanup(func() { SetExporter(nil) })
+}
+
+func TestBatcherExportsSampledSpans(t *testing.T) {
+	withSampleRatio(t, 1)
+	e := &fakeExporter{}
+	withExporter(t, e)
+
+	ctx, root := Start(context.Background(), "root", String("city", "chennai"))
+	_, child := Start(ctx, "child")
+	child.RecordError(errors.New("upstream down"))
+	child.End()
+	child.End() // a second End is ignored
+	child.SetAttributes(Int("late", 1))
+	root.End()
+
+	// Unsampled traces never reach the exporter
+	withSampleRatio(t, 0)
+	_, skipped := Start(context.Background(), "skipped")
+	skipped.End()
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := e.names()
+	if strings.Join(names, ",") != "child,root" {
+		t.Fatalf("exported %v, want [child root]", names)
+	}
+	if !e.closed {
+		t.Error("Shutdown didn't shut the exporter down")
+	}
+
+	got := e.batches[0][0]
+	if got.TraceID != root.sc.TraceID || got.ParentID != root.sc.SpanID || got.Kind != KindInternal {
+		t.Errorf("child span %+v doesn't point at root %+v", got, root.sc)
+	}
+	if got.Error != "upstream down" || len(got.Attrs) != 0 || got.End.Before(got.Start) {
+		t.Errorf("child span = %+v, want the error, no late attributes and End after Start", got)
+	}
+	if root := e.batches[0][1]; root.ParentID.IsValid() || len(root.Attrs) != 1 || root.Attrs[0] != String("city", "chennai") {
+		t.Errorf("root span = %+v", root)
+	}
+
+	// After Shutdown, spans go nowhere
+	_, late := Start(context.Background(), "late")
+	late.End()
+	if names, _ := e.names(); len(names) != 2 {
+		t.Errorf("span exported after Shutdown: %v", names)
+	}
+}
+
+func TestBatcherSplitsBatches(t *testing.T) {
+	withSampleRatio(t, 1)
+	e := &fakeExporter{block: make(chan struct{})}
+	withExporter(t, e)
+
+	const n = batchSize + 44
+	for range n {
+		_, s := Start(context.Background(), "span")
+		s.End()
+	}
+	close(e.block)
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	names, sizes := e.names()
+	if len(names) != n {
+		t.Errorf("exported %d spans, want %d", len(names), n)
+	}
+	for _, size := range sizes {
+		if size > batchSize {
+			t.Errorf("batch of %d spans, want at most %d", size, batchSize)
+		}
+	}
+}
+
+func TestBatcherDropsWhenFull(t *testing.T) {
+	withSampleRatio(t, 1)
+	e := &fakeExporter{block: make(chan struct{})}
+	withExporter(t, e)
+
+	// With the exporter stuck, End must still return straight away
+	const n = queueSize + 2*batchSize + 100
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range n {
+			_, s := Start(context.Background(), "span")
+			s.End()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("End blocked on a stuck exporter")
+	}
+
+	close(e.block)
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := e.names(); len(names) == 0 || len(names) >= n {
+		t.Errorf("exported %d of %d spans, want some dropped", len(names), n)
+	}
+}
+
+func TestShutdownGivesUp(t *testing.T) {
+	withSampleRatio(t, 1)
+	e := &fakeExporter{block: make(chan struct{})}
+	SetExporter(e)
+	t.Cleanup(func() { close(e.block) })
+
+	_, s := Start(context.Background(), "span")
+	s.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown with a stuck exporter = %v, want context.DeadlineExceeded", err)
+	}
+	if err := Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown with no exporter = %v", err)
+	}
+}
+
+func TestSetExporterReplaces(t *testing.T) {
+	withSampleRatio(t, 1)
+	first, second := &fakeExporter{}, &fakeExporter{}
+	withExporter(t, first)
+
+	_, s := Start(context.Background(), "one")
+	s.End()
+	SetExporter(second)
+	_, s = Start(context.Background(), "two")
+	s.End()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The old exporter is flushed and shut down when replaced
+	if names, _ := first.names(); strings.Join(names, ",") != "one" || !first.closed {
+		t.Errorf("first exporter got %v (closed %v), want [one] and closed", names, first.closed)
+	}
+	if names, _ := second.names(); strings.Join(names, ",") != "two" {
+		t.Errorf("second exporter got %v, want [two]", names)
+	}
+}
+
+// testSpans are a root span with attributes of every type and a failed child.
+func testSpans() []SpanData {
+	trace, _ := ParseTraceparent("00-" + testTraceID + "-" + testSpanID + "-01")
+	start := time.Unix(1700000000, 123456789).UTC()
+	return []SpanData{
+		{
+			TraceID: trace.TraceID,
+			SpanID:  trace.SpanID,
+			Name:    "GET /weather",
+			Kind:    KindServer,
+			Start:   start,
+			End:     start.Add(1500 * time.Microsecond),
+			Attrs:   []Attr{String("city", "chennai"), Int("status", 200), Float64("ratio", 0.5), Bool("cached", true)},
+		},
+		{
+			TraceID:  trace.TraceID,
+			SpanID:   SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+			ParentID: trace.SpanID,
+			Name:     "openmeteo.forecast",
+			Kind:     KindClient,
+			Start:    start,
+			End:      start.Add(time.Millisecond),
+			Error:    "upstream down",
+		},
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewWriterExporter(&buf, false)
+	if err := e.Export(context.Background(), testSpans()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","name":"GET /weather","kind":"server","start":"2023-11-14T22:13:20.123456789Z","duration_ms":1.5,"attributes":{"cached":true,"city":"chennai","ratio":0.5,"status":200}}
+{"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"0102030405060708","parent_span_id":"00f067aa0ba902b7","name":"openmeteo.forecast","kind":"client","start":"2023-11-14T22:13:20.123456789Z","duration_ms":1,"error":"upstream down"}
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+type closeRecorder struct {
+	io.Writer
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWriterExporterClose(t *testing.T) {
+	for _, close := range []bool{true, false} {
+		w := &closeRecorder{Writer: io.Discard}
+		if err := NewWriterExporter(w, close).Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if w.closed != close {
+			t.Errorf("NewWriterExporter(w, %v): closed = %v", close, w.closed)
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]any
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			http.Error(w, "wrong request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	e := NewOTLPExporter(srv.URL+"/v1/traces", "weather-server")
+	if err := e.Export(context.Background(), testSpans()); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+
+	// Compare against the expected OTLP/JSON, decoded the same way
+	var want map[string]any
+	if err := json.Unmarshal([]byte(`{"resourceSpans": [{
+		"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "weather-server"}}]},
+		"scopeSpans": [{
+			"scope": {"name": "weather-cli/server"},
+			"spans": [
+				{
+					"traceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+					"spanId": "00f067aa0ba902b7",
+					"name": "GET /weather",
+					"kind": 2,
+					"startTimeUnixNano": "1700000000123456789",
+					"endTimeUnixNano": "1700000000124956789",
+					"attributes": [
+						{"key": "city", "value": {"stringValue": "chennai"}},
+						{"key": "status", "value": {"intValue": "200"}},
+						{"key": "ratio", "value": {"doubleValue": 0.5}},
+						{"key": "cached", "value": {"boolValue": true}}
+					],
+					"status": {}
+				},
+				{
+					"traceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+					"spanId": "0102030405060708",
+					"parentSpanId": "00f067aa0ba902b7",
+					"name": "openmeteo.forecast",
+					"kind": 3,
+					"startTimeUnixNano": "1700000000123456789",
+					"endTimeUnixNano": "1700000000124456789",
+					"status": {"message": "upstream down", "code": 2}
+				}
+			]
+		}]
+	}]}`), &want); err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, _ := json.MarshalIndent(body, "", "  ")
+	wantJSON, _ := json.MarshalIndent(want, "", "  ")
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("posted:\n%s\nwant:\n%s", gotJSON, wantJSON)
+	}
+}
+
+func TestOTLPExporterErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	e := NewOTLPExporter(srv.URL, "weather-server")
+	err := e.Export(context.Background(), testSpans())
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Export to a failing collector = %v, want the 503", err)
+	}
+
+	// Nothing listening
+	srv.Close()
+	if err := e.Export(context.Background(), testSpans()); err == nil {
+		t.Error("Export to a closed server succeeded")
+	}
+
+	// A cancelled context stops the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewOTLPExporter(DefaultOTLPEndpoint, "x").Export(ctx, testSpans()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Export with a cancelled context = %v, want context.Canceled", err)
+	}
+}
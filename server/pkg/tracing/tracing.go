@@ -0,0 +1,305 @@
+// Package tracing is a small OpenTelemetry-style tracer: spans with
+// parent/child links carried through context.Context, W3C Trace Context
+// (traceparent) propagation over HTTP, and pluggable exporters.
+//
+// Start a span around any unit of work and End it when done:
+//
+//	ctx, span := tracing.Start(ctx, "gazetteer.lookup", tracing.String("city", city))
+//	defer span.End()
+//
+// Spans always get IDs, so traceparent is propagated even when nothing is
+// exported. Only sampled spans reach the exporter (see SetExporter).
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a whole trace, shared by every span in it.
+type TraceID [16]byte
+
+// SpanID identifies one span within a trace.
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether t is non-zero; all-zero IDs are invalid in W3C Trace Context.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether s is non-zero.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+func newTraceID() TraceID {
+	var t TraceID
+	binary.BigEndian.PutUint64(t[:8], rand.Uint64())
+	binary.BigEndian.PutUint64(t[8:], rand.Uint64())
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	for !s.IsValid() {
+		binary.BigEndian.PutUint64(s[:], rand.Uint64())
+	}
+	return s
+}
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// traceparentHeader is the W3C Trace Context header.
+const traceparentHeader = "traceparent"
+
+// Traceparent formats sc as a traceparent header value:
+// "00-<trace id>-<span id>-<flags>", flags 01 meaning sampled.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent reads a traceparent header value. Unknown future
+// versions are read as version 00, as the spec asks; anything malformed
+// (or version ff) returns false.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var version, flags [1]byte
+	if !decodeHex(version[:], parts[0]) || !decodeHex(sc.TraceID[:], parts[1]) ||
+		!decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 != 0
+	return sc, true
+}
+
+// decodeHex decodes lowercase hex s into dst, which must fit it exactly.
+func decodeHex(dst []byte, s string) bool {
+	if strings.ToLower(s) != s {
+		return false
+	}
+	n, err := hex.Decode(dst, []byte(s))
+	return err == nil && n == len(dst)
+}
+
+// SpanKind says what role a span plays, numbered as in OTLP.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+// Attr is a key/value attribute on a span. Make one with String, Int,
+// Float64 or Bool, so exporters only ever see those types.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attr      { return Attr{key, value} }
+func Int(key string, value int) Attr     { return Attr{key, int64(value)} }
+func Float64(key string, v float64) Attr { return Attr{key, v} }
+func Bool(key string, value bool) Attr   { return Attr{key, value} }
+
+// Span is one timed operation in a trace. Its methods are safe to call
+// from several goroutines, and on a span that has already ended (they do
+// nothing then).
+type Span struct {
+	mu     sync.Mutex
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   SpanKind
+	start  time.Time
+	attrs  []Attr
+	err    string
+	ended  bool
+}
+
+// SpanContext returns the span's IDs and sampling decision.
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ended {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// RecordError marks the span as failed with err. A nil err does nothing.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ended {
+		s.err = err.Error()
+	}
+}
+
+// End finishes the span and, if it is sampled, hands it to the exporter.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		TraceID:  s.sc.TraceID,
+		SpanID:   s.sc.SpanID,
+		ParentID: s.parent,
+		Name:     s.name,
+		Kind:     s.kind,
+		Start:    s.start,
+		End:      time.Now(),
+		Attrs:    s.attrs,
+		Error:    s.err,
+	}
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		export(data)
+	}
+}
+
+// spanKey is the context key for the current span.
+type spanKey struct{}
+
+// SpanFromContext returns the current span, or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start begins an internal span as a child of the span in ctx (or a new
+// trace) and returns a context carrying it.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	var parent SpanContext
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.sc
+	}
+	return start(ctx, name, KindInternal, parent, attrs)
+}
+
+// StartClient begins a span for an outgoing call. Pass the returned context
+// to Inject so the callee joins the trace under this span.
+func StartClient(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	var parent SpanContext
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.sc
+	}
+	return start(ctx, name, KindClient, parent, attrs)
+}
+
+// StartServer begins a span for an incoming request, continuing the
+// caller's trace if the request has a valid traceparent header.
+func StartServer(r *http.Request, name string, attrs ...Attr) (context.Context, *Span) {
+	parent, _ := ParseTraceparent(r.Header.Get(traceparentHeader))
+	return start(r.Context(), name, KindServer, parent, attrs)
+}
+
+func start(ctx context.Context, name string, kind SpanKind, parent SpanContext, attrs []Attr) (context.Context, *Span) {
+	s := &Span{
+		name:  name,
+		kind:  kind,
+		start: time.Now(),
+		attrs: attrs,
+	}
+	if parent.IsValid() {
+		// Follow the parent's sampling decision, so traces are never half-recorded
+		s.sc = SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+		s.parent = parent.SpanID
+	} else {
+		s.sc = SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampleRoot()}
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Inject adds a traceparent header for the span in ctx to h, so the server
+// receiving the request can attach its spans to ours.
+func Inject(ctx context.Context, h http.Header) {
+	if s := SpanFromContext(ctx); s != nil {
+		h.Set(traceparentHeader, s.sc.Traceparent())
+	}
+}
+
+// sampling holds the ratio of new traces that are recorded.
+var sampling struct {
+	mu    sync.Mutex
+	ratio float64
+}
+
+func init() {
+	sampling.ratio = 1
+}
+
+// SetSampleRatio sets the share of new traces (0 to 1) that are sampled.
+// Traces continued from a caller's traceparent keep the caller's decision.
+func SetSampleRatio(ratio float64) error {
+	if !(ratio >= 0 && ratio <= 1) { // NaN too
+		return fmt.Errorf("sample ratio must be between 0 and 1, got %g", ratio)
+	}
+	sampling.mu.Lock()
+	defer sampling.mu.Unlock()
+
+	sampling.ratio = ratio
+	return nil
+}
+
+func sampleRoot() bool {
+	sampling.mu.Lock()
+	ratio := sampling.ratio
+	sampling.mu.Unlock()
+
+	return ratio >= 1 || rand.Float64() < ratio
+}
@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-" + testTraceID + "-" + testSpanID + "-01"
+	tests := []struct {
+		name    string
+		in      string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", valid, true, true},
+		{"not sampled", "00-" + testTraceID + "-" + testSpanID + "-00", true, false},
+		{"other flags ignored", "00-" + testTraceID + "-" + testSpanID + "-09", true, true},
+		{"surrounding space", "  " + valid + " ", true, true},
+		{"future version", "01-" + testTraceID + "-" + testSpanID + "-01", true, true},
+		{"future version with extra fields", "cc-" + testTraceID + "-" + testSpanID + "-01-what-comes-next", true, true},
+
+		{"empty", "", false, false},
+		{"version ff", "ff-" + testTraceID + "-" + testSpanID + "-01", false, false},
+		{"version 00 with extra fields", valid + "-extra", false, false},
+		{"version 00 with trailing dash", valid + "-", false, false},
+		{"bad version", "0g-" + testTraceID + "-" + testSpanID + "-01", false, false},
+		{"all-zero trace ID", "00-00000000000000000000000000000000-" + testSpanID + "-01", false, false},
+		{"all-zero span ID", "00-" + testTraceID + "-0000000000000000-01", false, false},
+		{"uppercase trace ID", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + testSpanID + "-01", false, false},
+		{"uppercase span ID", "00-" + testTraceID + "-00F067AA0BA902B7-01", false, false},
+		{"uppercase version", "0A-" + testTraceID + "-" + testSpanID + "-01", false, false},
+		{"short trace ID", "00-" + testTraceID[1:] + "-" + testSpanID + "-01", false, false},
+		{"long trace ID", "00-" + testTraceID + "0-" + testSpanID + "-01", false, false},
+		{"short span ID", "00-" + testTraceID + "-" + testSpanID[1:] + "-01", false, false},
+		{"long span ID", "00-" + testTraceID + "-" + testSpanID + "0-01", false, false},
+		{"short flags", "00-" + testTraceID + "-" + testSpanID + "-1", false, false},
+		{"long flags", "00-" + testTraceID + "-" + testSpanID + "-011", false, false},
+		{"future version, flags run on", "01-" + testTraceID + "-" + testSpanID + "-01xyz", false, false},
+		{"bad flags", "00-" + testTraceID + "-" + testSpanID + "-zz", false, false},
+		{"non-hex trace ID", "00-" + testTraceID[:31] + "x-" + testSpanID + "-01", false, false},
+		{"missing field", "00-" + testTraceID + "-" + testSpanID, false, false},
+		{"wrong separator", "00_" + testTraceID + "_" + testSpanID + "_01", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.in)
+			if ok != tt.ok {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tt.in, ok, tt.ok)
+			}
+			if !ok {
+				if sc != (SpanContext{}) {
+					t.Errorf("rejected header gave %+v, want the zero SpanContext", sc)
+				}
+				return
+			}
+			if sc.TraceID.String() != testTraceID || sc.SpanID.String() != testSpanID || sc.Sampled != tt.sampled {
+				t.Errorf("ParseTraceparent(%q) = %s %s sampled=%v", tt.in, sc.TraceID, sc.SpanID, sc.Sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, sampled := range []bool{true, false} {
+		sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampled}
+		got, ok := ParseTraceparent(sc.Traceparent())
+		if !ok || got != sc {
+			t.Errorf("ParseTraceparent(%q) = %+v, %v, want %+v", sc.Traceparent(), got, ok, sc)
+		}
+	}
+
+	// A future version is passed on as version 00
+	sc, _ := ParseTraceparent("01-" + testTraceID + "-" + testSpanID + "-01-extra")
+	if want := "00-" + testTraceID + "-" + testSpanID + "-01"; sc.Traceparent() != want {
+		t.Errorf("Traceparent() = %q, want %q", sc.Traceparent(), want)
+	}
+}
+
+// withSampleRatio sets the sample ratio for the duration of the test.
+func withSampleRatio(t *testing.T, ratio float64) {
+	t.Helper()
+	if err := SetSampleRatio(ratio); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetSampleRatio(1) })
+}
+
+func TestSetSampleRatio(t *testing.T) {
+	t.Cleanup(func() { SetSampleRatio(1) })
+	for _, ratio := range []float64{0, 0.25, 1} {
+		if err := SetSampleRatio(ratio); err != nil {
+			t.Errorf("SetSampleRatio(%g) = %v", ratio, err)
+		}
+	}
+	for _, ratio := range []float64{-0.1, 1.5, math.NaN(), math.Inf(1)} {
+		if err := SetSampleRatio(ratio); err == nil {
+			t.Errorf("SetSampleRatio(%g) accepted", ratio)
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	sampledRoots := func() int {
+		n := 0
+		for range 1000 {
+			if _, s := Start(context.Background(), "root"); s.SpanContext().Sampled {
+				n++
+			}
+		}
+		return n
+	}
+
+	withSampleRatio(t, 0)
+	if n := sampledRoots(); n != 0 {
+		t.Errorf("ratio 0 sampled %d of 1000 roots", n)
+	}
+	withSampleRatio(t, 1)
+	if n := sampledRoots(); n != 1000 {
+		t.Errorf("ratio 1 sampled %d of 1000 roots", n)
+	}
+	withSampleRatio(t, 0.5)
+	if n := sampledRoots(); n < 400 || n > 600 {
+		t.Errorf("ratio 0.5 sampled %d of 1000 roots", n)
+	}
+
+	// Children follow their parent whatever the ratio
+	for _, ratio := range []float64{0, 1} {
+		withSampleRatio(t, ratio)
+		for _, sampled := range []bool{true, false} {
+			parent := &Span{sc: SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: sampled}}
+			ctx := context.WithValue(context.Background(), spanKey{}, parent)
+			_, child := Start(ctx, "child")
+			if child.SpanContext().Sampled != sampled {
+				t.Errorf("ratio %g: child of a sampled=%v parent has sampled=%v", ratio, sampled, child.SpanContext().Sampled)
+			}
+		}
+	}
+}
+
+func TestStartChildren(t *testing.T) {
+	ctx, root := Start(context.Background(), "root")
+	if SpanFromContext(ctx) != root {
+		t.Fatal("Start didn't put the span in the context")
+	}
+	if root.parent.IsValid() || !root.sc.IsValid() {
+		t.Errorf("root span: parent %s, context %+v", root.parent, root.sc)
+	}
+
+	ctx, client := StartClient(ctx, "upstream")
+	if client.sc.TraceID != root.sc.TraceID || client.parent != root.sc.SpanID || client.kind != KindClient {
+		t.Errorf("client span %+v (parent %s), want a client child of %+v", client.sc, client.parent, root.sc)
+	}
+	if client.sc.SpanID == root.sc.SpanID {
+		t.Error("child reused its parent's span ID")
+	}
+
+	// Inject and StartServer carry the trace across HTTP
+	req := httptest.NewRequest("GET", "/weather", nil)
+	Inject(ctx, req.Header)
+	_, server := StartServer(req, "GET /weather")
+	if server.sc.TraceID != root.sc.TraceID || server.parent != client.sc.SpanID || server.kind != KindServer {
+		t.Errorf("server span %+v (parent %s), want a server child of %+v", server.sc, server.parent, client.sc)
+	}
+
+	// No context span, no header
+	h := http.Header{}
+	Inject(context.Background(), h)
+	if len(h) != 0 {
+		t.Errorf("Inject without a span set %v", h)
+	}
+
+	// A bad header starts a new trace
+	req = httptest.NewRequest("GET", "/weather", nil)
+	req.Header.Set("traceparent", "00-"+testTraceID+"-0000000000000000-01")
+	if _, s := StartServer(req, "GET /weather"); s.parent.IsValid() || s.sc.TraceID.String() == testTraceID {
+		t.Errorf("span from a bad traceparent joined its trace: %+v", s.sc)
+	}
+}
@@ -0,0 +1,137 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MaxBatchSize caps how many locations one batch request may ask for.
+const MaxBatchSize = 50
+
+// DefaultBatchWorkers is how many batch items are fetched at once by default.
+const DefaultBatchWorkers = 8
+
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// batchWorkers bounds the concurrency of GetWeatherBatch.
+var batchWorkers = DefaultBatchWorkers
+
+// SetBatchWorkers configures how many batch items are fetched concurrently.
+// Values below 1 restore the default.
+func SetBatchWorkers(n int) {
+	if n < 1 {
+		n = DefaultBatchWorkers
+	}
+	batchWorkers = n
+}
+
+// BatchQuery is one location in a batch: a city name, or a coordinate.
+type BatchQuery struct {
+	City string   `json:"city,omitempty"`
+	Lat  *float64 `json:"lat,omitempty"`
+	Lon  *float64 `json:"lon,omitempty"`
+}
+
+// String describes the query, e.g. "chennai" or "13.08,80.27".
+func (q BatchQuery) String() string {
+	if q.Lat != nil && q.Lon != nil {
+		return fmt.Sprintf("%g,%g", *q.Lat, *q.Lon)
+	}
+	return q.City
+}
+
+// validate checks that q is exactly one of a city or a full coordinate.
+func (q BatchQuery) validate() error {
+	hasCity := strings.TrimSpace(q.City) != ""
+	hasCoords := q.Lat != nil || q.Lon != nil
+	switch {
+	case hasCity && hasCoords:
+		return fmt.Errorf("%w: use either city or lat/lon, not both", ErrInvalidBatch)
+	case hasCoords && (q.Lat == nil || q.Lon == nil):
+		return fmt.Errorf("%w: lat and lon must be given together", ErrInvalidBatch)
+	case !hasCity && !hasCoords:
+		return fmt.Errorf("%w: each location needs a city or lat/lon", ErrInvalidBatch)
+	}
+	return nil
+}
+
+// BatchResult is the outcome of one BatchQuery: the weather, or the error
+// that query alone ran into.
+type BatchResult struct {
+	Query   BatchQuery
+	Weather WeatherResp
+	Err     error
+}
+
+// ValidateBatch checks a batch before any work is done: its size, and that
+// every query names a city or a coordinate.
+func ValidateBatch(queries []BatchQuery) error {
+	if len(queries) == 0 {
+		return fmt.Errorf("%w: no locations given", ErrInvalidBatch)
+	}
+	if len(queries) > MaxBatchSize {
+		return fmt.Errorf("%w: at most %d locations per batch", ErrInvalidBatch, MaxBatchSize)
+	}
+	for i, q := range queries {
+		if err := q.validate(); err != nil {
+			return fmt.Errorf("location %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// GetWeatherBatch fetches current conditions for every query, at most
+// SetBatchWorkers at a time, and returns one result per query in the same
+// order. A failing query (unknown city, upstream error) only fails its own
+// result. Each query goes through the cache like GetWeather, so cache hits
+// come back without an upstream call, and repeated locations share a fetch.
+// If ctx ends first, queries not yet started fail with ctx's error.
+func GetWeatherBatch(ctx context.Context, queries []BatchQuery) []BatchResult {
+	results := make([]BatchResult, len(queries))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(batchWorkers, len(queries)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = getBatchItem(ctx, queries[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range queries {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			// The client went away: don't start the queries still waiting
+			for j := i; j < len(queries); j++ {
+				results[j] = BatchResult{Query: queries[j], Err: ctx.Err()}
+			}
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// getBatchItem fetches the weather for a single query.
+func getBatchItem(ctx context.Context, q BatchQuery) BatchResult {
+	res := BatchResult{Query: q}
+	if res.Err = q.validate(); res.Err != nil {
+		return res
+	}
+	if q.Lat != nil {
+		res.Weather, res.Err = GetWeatherAt(ctx, *q.Lat, *q.Lon)
+	} else {
+		res.Weather, res.Err = GetWeather(ctx, q.City)
+	}
+	return res
+}
@@ -0,0 +1,172 @@
+package weather
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a provider's circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through (the healthy state).
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects calls until the cool-down has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through to test recovery.
+	BreakerHalfOpen
+)
+
+// String returns the state name used in diagnostics ("closed", "open", "half-open").
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// MarshalText makes the state show up as its name in JSON.
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Breaker is a consecutive-failure circuit breaker.
+//
+// After `threshold` failures in a row it opens and rejects calls, so a dead
+// upstream costs nothing instead of a full timeout per request. Once the
+// cool-down has passed it goes half-open and lets exactly one probe through:
+// success closes it again, failure re-opens it for another cool-down.
+//
+// Every state change starts a new generation, and each allowed call carries
+// the generation it was let through in. A slow call that started while the
+// breaker was closed may finish after it opened; its result is about the old
+// state, so it is ignored rather than closing the breaker behind the
+// cool-down's back.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	state      BreakerState
+	generation uint64    // bumped on every state change
+	failures   int       // consecutive failures while closed
+	openedAt   time.Time // when the breaker last opened
+	probing    bool      // a half-open probe is in flight
+}
+
+// NewBreaker returns a closed breaker that opens after threshold consecutive
+// failures and half-opens after cooldown.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow reports whether a call may go through right now, and the generation
+// it goes through in. Every allowed call must be followed by Success, Failure
+// or Release with that generation.
+func (b *Breaker) Allow() (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return 0, false
+		}
+		// Cool-down over: let one probe through
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return b.generation, true
+	case BreakerHalfOpen:
+		// Only one probe at a time; everyone else skips this provider
+		if b.probing {
+			return 0, false
+		}
+		b.probing = true
+		return b.generation, true
+	}
+	return b.generation, true
+}
+
+// setState moves to state and starts a new generation. b.mu must be held.
+func (b *Breaker) setState(state BreakerState) {
+	b.state = state
+	b.generation++
+}
+
+// Success records a successful call and closes the breaker.
+// Results from an earlier generation are ignored.
+func (b *Breaker) Success(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	if b.state != BreakerClosed {
+		b.setState(BreakerClosed)
+	}
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a failed call, opening the breaker when the threshold is
+// reached or when a half-open probe fails.
+// Results from an earlier generation are ignored.
+func (b *Breaker) Failure(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.setState(BreakerOpen)
+		b.openedAt = time.Now()
+	}
+}
+
+// Release gives back an allowed call that ended without telling us anything
+// about the provider (e.g. the caller went away), so a half-open probe slot
+// isn't held forever. A call from an earlier generation holds no slot.
+func (b *Breaker) Release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.generation {
+		b.probing = false
+	}
+}
+
+// State returns the current state, reporting an open breaker whose cool-down
+// has passed as half-open (that's what the next call will see).
+func (b *Breaker) State() BreakerState {
+	state, _, _ := b.snapshot()
+	return state
+}
+
+// snapshot returns the state, the consecutive failure count and, while open,
+// the time the breaker will half-open.
+func (b *Breaker) snapshot() (BreakerState, int, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	var retryAt time.Time
+	if state == BreakerOpen {
+		retryAt = b.openedAt.Add(b.cooldown)
+		if !time.Now().Before(retryAt) {
+			state = BreakerHalfOpen
+		}
+	}
+	return state, b.failures, retryAt
+}
@@ -0,0 +1,195 @@
+package weather
+
+import (
+	"testing"
+	"time"
+)
+
+// breakerStep is one call into a Breaker. Allowed calls are named, so later
+// steps can report results for the generation that call was let through in.
+type breakerStep struct {
+	op   string // allow, success, failure, release or expire (end the cool-down)
+	call string
+	want bool         // for allow: whether the call is let through
+	then BreakerState // state after the step (the zero value is closed)
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []breakerStep
+	}{
+		{
+			name:      "opens after threshold consecutive failures",
+			threshold: 2,
+			steps: []breakerStep{
+				{op: "allow", call: "a", want: true, then: BreakerClosed},
+				{op: "failure", call: "a", then: BreakerClosed},
+				{op: "allow", call: "b", want: true, then: BreakerClosed},
+				{op: "failure", call: "b", then: BreakerOpen},
+				{op: "allow", call: "c", want: false, then: BreakerOpen},
+			},
+		},
+		{
+			name:      "success resets the failure count",
+			threshold: 2,
+			steps: []breakerStep{
+				{op: "allow", call: "a", want: true, then: BreakerClosed},
+				{op: "failure", call: "a", then: BreakerClosed},
+				{op: "allow", call: "b", want: true, then: BreakerClosed},
+				{op: "success", call: "b", then: BreakerClosed},
+				{op: "allow", call: "c", want: true, then: BreakerClosed},
+				{op: "failure", call: "c", then: BreakerClosed},
+			},
+		},
+		{
+			name:      "one half-open probe at a time, success closes",
+			threshold: 1,
+			steps: []breakerStep{
+				{op: "allow", call: "a", want: true, then: BreakerClosed},
+				{op: "failure", call: "a", then: BreakerOpen},
+				{op: "expire", then: BreakerHalfOpen},
+				{op: "allow", call: "probe", want: true, then: BreakerHalfOpen},
+				{op: "allow", call: "other", want: false, then: BreakerHalfOpen},
+				{op: "success", call: "probe", then: BreakerClosed},
+				{op: "allow", call: "b", want: true, then: BreakerClosed},
+			},
+		},
+		{
+			name:      "failed probe re-opens for another cool-down",
+			threshold: 3,
+			steps: []breakerStep{
+				{op: "allow", call: "a", want: true},
+				{op: "failure", call: "a"},
+				{op: "allow", call: "b", want: true},
+				{op: "failure", call: "b"},
+				{op: "allow", call: "c", want: true},
+				{op: "failure", call: "c", then: BreakerOpen},
+				{op: "expire", then: BreakerHalfOpen},
+				{op: "allow", call: "probe", want: true, then: BreakerHalfOpen},
+				// A single failed probe is enough, whatever the threshold
+				{op: "failure", call: "probe", then: BreakerOpen},
+				{op: "allow", call: "d", want: false, then: BreakerOpen},
+			},
+		},
+		{
+			name:      "release frees the probe slot",
+			threshold: 1,
+			steps: []breakerStep{
+				{op: "allow", call: "a", want: true},
+				{op: "failure", call: "a", then: BreakerOpen},
+				{op: "expire", then: BreakerHalfOpen},
+				{op: "allow", call: "probe", want: true, then: BreakerHalfOpen},
+				{op: "release", call: "probe", then: BreakerHalfOpen},
+				{op: "allow", call: "probe2", want: true, then: BreakerHalfOpen},
+			},
+		},
+		{
+			name:      "late success from before opening is ignored",
+			threshold: 1,
+			steps: []breakerStep{
+				{op: "allow", call: "slow", want: true},
+				{op: "allow", call: "fast", want: true},
+				{op: "failure", call: "fast", then: BreakerOpen},
+				// The slow call started while closed; it says nothing about now
+				{op: "success", call: "slow", then: BreakerOpen},
+				{op: "allow", call: "c", want: false, then: BreakerOpen},
+			},
+		},
+		{
+			name:      "late failure does not take over the probe",
+			threshold: 1,
+			steps: []breakerStep{
+				{op: "allow", call: "slow", want: true},
+				{op: "allow", call: "fast", want: true},
+				{op: "failure", call: "fast", then: BreakerOpen},
+				{op: "expire", then: BreakerHalfOpen},
+				{op: "allow", call: "probe", want: true, then: BreakerHalfOpen},
+				{op: "failure", call: "slow", then: BreakerHalfOpen},
+				{op: "allow", call: "other", want: false, then: BreakerHalfOpen},
+				{op: "success", call: "probe", then: BreakerClosed},
+			},
+		},
+		{
+			name:      "late release does not free the probe slot",
+			threshold: 1,
+			steps: []breakerStep{
+				{op: "allow", call: "slow", want: true},
+				{op: "allow", call: "fast", want: true},
+				{op: "failure", call: "fast", then: BreakerOpen},
+				{op: "expire", then: BreakerHalfOpen},
+				{op: "allow", call: "probe", want: true, then: BreakerHalfOpen},
+				{op: "release", call: "slow", then: BreakerHalfOpen},
+				{op: "allow", call: "other", want: false, then: BreakerHalfOpen},
+			},
+		},
+		{
+			name:      "late success after recovery doesn't disturb the new state",
+			threshold: 2,
+			steps: []breakerStep{
+				{op: "allow", call: "slow", want: true},
+				{op: "allow", call: "a", want: true},
+				{op: "failure", call: "a"},
+				{op: "allow", call: "b", want: true},
+				{op: "failure", call: "b", then: BreakerOpen},
+				{op: "expire", then: BreakerHalfOpen},
+				{op: "allow", call: "probe", want: true, then: BreakerHalfOpen},
+				{op: "success", call: "probe", then: BreakerClosed},
+				{op: "allow", call: "c", want: true},
+				{op: "failure", call: "c", then: BreakerClosed},
+				// Had the slow call counted, it would have reset c's failure
+				{op: "success", call: "slow", then: BreakerClosed},
+				{op: "allow", call: "d", want: true},
+				{op: "failure", call: "d", then: BreakerOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker(tt.threshold, time.Hour)
+			generations := make(map[string]uint64)
+
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					gen, ok := b.Allow()
+					if ok != s.want {
+						t.Fatalf("step %d: Allow() for %s = %v, want %v", i, s.call, ok, s.want)
+					}
+					generations[s.call] = gen
+				case "success":
+					b.Success(generations[s.call])
+				case "failure":
+					b.Failure(generations[s.call])
+				case "release":
+					b.Release(generations[s.call])
+				case "expire":
+					b.mu.Lock()
+					b.openedAt = time.Now().Add(-b.cooldown)
+					b.mu.Unlock()
+				default:
+					t.Fatalf("step %d: unknown op %q", i, s.op)
+				}
+
+				if got := b.State(); got != s.then {
+					t.Fatalf("step %d (%s %s): state = %v, want %v", i, s.op, s.call, got, s.then)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerStateNames(t *testing.T) {
+	for state, want := range map[BreakerState]string{
+		BreakerClosed:   "closed",
+		BreakerOpen:     "open",
+		BreakerHalfOpen: "half-open",
+		BreakerState(9): "unknown",
+	} {
+		if got, _ := state.MarshalText(); string(got) != want {
+			t.Errorf("%d marshals as %q, want %q", state, got, want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package weather
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+	"weather-cli/server/pkg/cache"
+)
+
+// Cross-instance lock timings.
+const (
+	// lockLease is how long a distributed fetch lock lives. It only has to cover
+	// one upstream fetch; if the holder dies, others take over after this.
+	lockLease = 5 * time.Second
+	// lockPollInterval is how often instances that lost the lock re-check the cache.
+	lockPollInterval = 100 * time.Millisecond
+)
+
+// Locker is an optional cross-instance lock, so N replicas missing the cache
+// at the same moment still make a single upstream fetch.
+// *cache.Client implements it with a Redis SET NX lease.
+type Locker interface {
+	// TryLock attempts to take the fetch lock for a cache entry without blocking.
+	// When acquired, unlock must be called once the fetch is done.
+	TryLock(ctx context.Context, ns cache.Namespace, city string, lease time.Duration) (unlock func(), acquired bool, err error)
+}
+
+var locker Locker
+
+// SetLocker configures the distributed fetch lock.
+// Pass nil to only coalesce requests within this process.
+func SetLocker(l Locker) {
+	locker = l
+}
+
+// call is an in-flight or completed fetch shared by every caller of the same key.
+type call struct {
+	done chan struct{}
+	val  any
+	err  error
+}
+
+// flightGroup coalesces concurrent calls with the same key into one execution.
+// (A tiny version of golang.org/x/sync/singleflight.)
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+var flights flightGroup
+
+// do runs fn once for all concurrent callers with the same key.
+//
+// fn is shared, so it must not fail because whichever caller happened to
+// start it went away: it runs in its own goroutine on a context detached from
+// the caller's (keeping its values, like the request ID), bounded by
+// fetchTimeout. Every caller, the first one included, stops waiting when its
+// own ctx is done; the fetch carries on and fills the cache for the others.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) (any, error)) (any, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		g.calls[key] = c
+
+		// During shutdown the fetch still runs for the callers waiting on
+		// it, it just isn't waited for
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+		tracked := refreshes.start()
+		go func() {
+			if tracked {
+				defer refreshes.done()
+			}
+			defer cancel()
+
+			c.val, c.err = fn(fetchCtx)
+
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchTimeout bounds a shared upstream fetch and a background
+// stale-while-revalidate refresh, neither of which inherits a request's deadline.
+const fetchTimeout = 15 * time.Second
+
+// serveStale enables stale-while-revalidate: stale cache entries are returned
+// immediately (marked stale) while a background refresh fetches new data.
+// Because a failing refresh leaves the old entry in place, this also keeps
+// serving stale data through upstream outages, until the stale TTL runs out.
+var serveStale = true
+
+// SetServeStale turns stale-while-revalidate / serve-stale-on-error on or off.
+// When off, stale entries are treated as cache misses.
+func SetServeStale(enabled bool) {
+	serveStale = enabled
+}
+
+// freshnessSetter is implemented by response types embedding Freshness.
+type freshnessSetter interface {
+	setFreshness(fetchedAt time.Time, stale bool)
+}
+
+// markFreshness records on v when its data was fetched and whether it is stale.
+func markFreshness(v any, fetchedAt time.Time, stale bool) {
+	if f, ok := v.(freshnessSetter); ok {
+		f.setFreshness(fetchedAt, stale)
+	}
+}
+
+// loadThrough returns the cached value for key in namespace ns, or fetches and
+// caches it. Concurrent misses for the same key share one fetch in this process,
+// and, when a Locker is configured, across instances too.
+// A stale entry is returned right away while a background refresh runs.
+func loadThrough[T any](ctx context.Context, ns cache.Namespace, key string, now time.Time, fetch func(context.Context) (T, error)) (T, error) {
+	var out T
+	entry := cacheGet(ctx, ns, key, now, &out)
+	if entry != nil && (!entry.Stale || serveStale) {
+		// Once shutting down, keep serving the stale entry but don't refresh it
+		if entry.Stale && refreshes.start() {
+			go func() {
+				defer refreshes.done()
+				refresh(ctx, ns, key, fetch)
+			}()
+		}
+		markFreshness(&out, entry.StoredAt, entry.Stale)
+		return out, nil
+	}
+
+	v, err := flights.do(ctx, string(ns)+":"+key, func(ctx context.Context) (any, error) {
+		return fetchOnce(ctx, ns, key, now, fetch)
+	})
+	if err != nil {
+		return out, err
+	}
+	out = v.(T)
+	markFreshness(&out, now, false)
+	return out, nil
+}
+
+// refreshes tracks background refreshes and shared fetches still running, so
+// shutdown can let them finish writing to the cache before it is closed.
+var refreshes tracker
+
+// tracker counts running background work. Unlike a sync.WaitGroup, work may
+// start while someone is waiting (a request that outlived the HTTP server's
+// shutdown timeout, say): once wait is called, start refuses new work instead
+// of racing it.
+type tracker struct {
+	mu       sync.Mutex
+	running  int
+	stopping bool
+	idle     chan struct{} // closed once stopping and nothing is running
+}
+
+// start registers a unit of work, returning false if shutdown has begun.
+// Call done when work that was started finishes.
+func (t *tracker) start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopping {
+		return false
+	}
+	t.running++
+	return true
+}
+
+func (t *tracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.running--
+	if t.stopping && t.running == 0 {
+		close(t.idle)
+	}
+}
+
+// wait stops new work from starting and blocks until the running work has
+// finished or ctx is done.
+func (t *tracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.stopping {
+		t.stopping = true
+		t.idle = make(chan struct{})
+		if t.running == 0 {
+			close(t.idle)
+		}
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitForRefreshes blocks until every background refresh and shared fetch
+// has finished, or ctx is done. Call it once no more requests are being
+// served; stale entries are no longer refreshed after it is called.
+func WaitForRefreshes(ctx context.Context) error {
+	return refreshes.wait(ctx)
+}
+
+// refresh re-fetches a stale entry in the background. It joins any fetch for
+// the same key already in flight, so a burst of stale hits makes one call.
+// It outlives the request that triggered it, but keeps that request's context
+// values (like its request ID) so its logs can be traced back to it.
+func refresh[T any](parent context.Context, ns cache.Namespace, key string, fetch func(context.Context) (T, error)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(parent), fetchTimeout)
+	defer cancel()
+
+	now := time.Now()
+	_, err := flights.do(ctx, string(ns)+":"+key, func(ctx context.Context) (any, error) {
+		return fetchOnce(ctx, ns, key, now, fetch)
+	})
+	if err != nil {
+		// The stale entry stays in place and keeps being served
+		slog.WarnContext(ctx, "background refresh failed", "namespace", ns, "key", key, "err", err)
+	}
+}
+
+// fetchOnce is the leader's side of loadThrough: take the distributed lock if
+// configured, fetch from upstream and fill the cache.
+func fetchOnce[T any](ctx context.Context, ns cache.Namespace, key string, now time.Time, fetch func(context.Context) (T, error)) (T, error) {
+	var out T
+
+	if locker != nil {
+		unlock, acquired, err := locker.TryLock(ctx, ns, key, lockLease)
+		switch {
+		case err != nil:
+			// Lock backend trouble shouldn't fail the request; just fetch
+			slog.WarnContext(ctx, "fetch lock failed", "namespace", ns, "key", key, "err", err)
+		case acquired:
+			defer unlock()
+			// Another instance may have refreshed the cache just before we got the lock
+			if entry, _ := cacheLookup(ctx, ns, key, now, &out); entry != nil && !entry.Stale {
+				return out, nil
+			}
+		default:
+			// Another instance is fetching: wait for its result to land in the cache
+			if waitForCache(ctx, ns, key, now, &out) {
+				slog.InfoContext(ctx, "cache filled by another instance", "namespace", ns, "key", key)
+				return out, nil
+			}
+			if ctx.Err() != nil {
+				return out, ctx.Err()
+			}
+			// The holder's lease ran out without a result; fetch ourselves
+		}
+	}
+
+	out, err := fetch(ctx)
+	if err != nil {
+		return out, err
+	}
+
+	cacheSet(ctx, ns, key, now, out)
+	return out, nil
+}
+
+// waitForCache polls the cache until a fresh entry appears, the lock lease
+// would have expired, or ctx is done. Returns true on a fresh hit.
+func waitForCache(ctx context.Context, ns cache.Namespace, key string, now time.Time, v any) bool {
+	deadline := time.Now().Add(lockLease)
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+		if entry, _ := cacheLookup(ctx, ns, key, now, v); entry != nil && !entry.Stale {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitingCtx reports when flightGroup.do starts waiting on it: do only asks
+// for Done once the caller has started or joined the call.
+type waitingCtx struct {
+	context.Context
+	once    sync.Once
+	waiting *sync.WaitGroup
+}
+
+func (c *waitingCtx) Done() <-chan struct{} {
+	c.once.Do(c.waiting.Done)
+	return c.Context.Done()
+}
+
+func TestFlightGroupSharesOneCall(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		<-release
+		return "sunny", nil
+	}
+
+	const callers = 10
+	var waiting, finished sync.WaitGroup
+	waiting.Add(callers)
+	finished.Add(callers)
+	results := make([]any, callers)
+	for i := range callers {
+		go func() {
+			defer finished.Done()
+			ctx := &waitingCtx{Context: context.Background(), waiting: &waiting}
+			v, err := g.do(ctx, "weather:mumbai", fn)
+			if err != nil {
+				t.Errorf("caller %d: %v", i, err)
+			}
+			results[i] = v
+		}()
+	}
+	waiting.Wait()
+	close(release)
+	finished.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v != "sunny" {
+			t.Errorf("caller %d got %v", i, v)
+		}
+	}
+
+	// Once done, the key is free and the next call fetches again
+	release = make(chan struct{})
+	close(release)
+	if _, err := g.do(context.Background(), "weather:mumbai", fn); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("fn ran %d times after the first call finished, want 2", n)
+	}
+}
+
+func TestFlightGroupSharesErrors(t *testing.T) {
+	var g flightGroup
+	errUpstream := errors.New("upstream down")
+	_, err := g.do(context.Background(), "k", func(ctx context.Context) (any, error) {
+		return nil, errUpstream
+	})
+	if !errors.Is(err, errUpstream) {
+		t.Errorf("err = %v, want %v", err, errUpstream)
+	}
+}
+
+type ctxKey struct{}
+
+func TestFlightGroupDetachesFetchFromFirstCaller(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetchErr := make(chan error, 1)
+	fn := func(ctx context.Context) (any, error) {
+		if ctx.Value(ctxKey{}) != "req-1" {
+			t.Error("fetch context lost the first caller's values")
+		}
+		close(started)
+		<-release
+		fetchErr <- ctx.Err()
+		return "sunny", nil
+	}
+
+	// The first caller gives up while the fetch is running
+	first, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "req-1"))
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := g.do(first, "k", fn)
+		firstErr <- err
+	}()
+	<-started
+
+	var waiting sync.WaitGroup
+	waiting.Add(1)
+	second := make(chan any, 1)
+	go func() {
+		v, _ := g.do(&waitingCtx{Context: context.Background(), waiting: &waiting}, "k", fn)
+		second <- v
+	}()
+	waiting.Wait()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller err = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if err := <-fetchErr; err != nil {
+		t.Errorf("fetch context was cancelled with its first caller: %v", err)
+	}
+	if v := <-second; v != "sunny" {
+		t.Errorf("second caller got %v, want the shared result", v)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	var tr tracker
+	if !tr.start() || !tr.start() {
+		t.Fatal("start refused before shutdown")
+	}
+	tr.done()
+
+	// One unit is still running: wait gives up with ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tr.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait with work running = %v, want context.DeadlineExceeded", err)
+	}
+	if tr.start() {
+		t.Error("start accepted new work after wait was called")
+	}
+
+	// Finishing the last unit releases a later wait
+	done := make(chan error, 1)
+	go func() { done <- tr.wait(context.Background()) }()
+	tr.done()
+	if err := <-done; err != nil {
+		t.Errorf("wait after the last unit finished = %v", err)
+	}
+	if err := tr.wait(context.Background()); err != nil {
+		t.Errorf("repeated wait = %v", err)
+	}
+}
+
+func TestTrackerIdle(t *testing.T) {
+	var tr tracker
+	if err := tr.wait(context.Background()); err != nil {
+		t.Errorf("wait with nothing running = %v", err)
+	}
+}
+
+// Starts racing a wait must either be refused or be waited for; run with -race.
+func TestTrackerStartDuringWait(t *testing.T) {
+	var tr tracker
+	var running atomic.Int32
+	var launched sync.WaitGroup
+	for range 50 {
+		launched.Add(1)
+		go func() {
+			defer launched.Done()
+			if tr.start() {
+				running.Add(1)
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+				tr.done()
+			}
+		}()
+	}
+	if err := tr.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := running.Load(); n != 0 {
+		t.Errorf("wait returned with %d units still running", n)
+	}
+	launched.Wait()
+}
@@ -0,0 +1,76 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"weather-cli/server/pkg/tracing"
+
+	"example.com/locations"
+	"example.com/openmeteo"
+)
+
+// NearestCityRadiusKm is how close a known city must be for GetWeatherAt to
+// report its name; further away the coordinates themselves are used.
+const NearestCityRadiusKm = 50
+
+// ErrInvalidCoordinates is returned for out-of-range lat/lon. It is the error
+// openmeteo.ParseCoordinates wraps, so handlers can check for either with one errors.Is.
+var ErrInvalidCoordinates = openmeteo.ErrInvalidCoordinates
+
+// GetWeatherAt returns current conditions at a coordinate, without going
+// through the city gazetteer. WeatherResp.City (and its country and
+// timezone) is the nearest known city within NearestCityRadiusKm, or the
+// coordinates themselves.
+func GetWeatherAt(ctx context.Context, lat, lon float64) (WeatherResp, error) {
+	ctx, span := tracing.Start(ctx, "weather.GetWeatherAt", tracing.Float64("lat", lat), tracing.Float64("lon", lon))
+	defer span.End()
+
+	if math.IsNaN(lat) || math.IsNaN(lon) || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return WeatherResp{}, ErrInvalidCoordinates
+	}
+
+	coordKey := fmt.Sprintf("%.4f,%.4f", lat, lon)
+	loc := locations.Location{Name: coordKey, Latitude: lat, Longitude: lon}
+	if city, ok := nearestCity(lat, lon); ok {
+		loc.Name, loc.Names, loc.Country, loc.Timezone = city.Name, city.Names, city.Country, city.Timezone
+	}
+
+	resp, err := currentAt(ctx, coordKey, loc)
+	span.RecordError(err)
+	return resp, err
+}
+
+// MaxNearestCities caps how many cities NearestCities returns.
+const MaxNearestCities = 50
+
+// NearestCities returns up to n known cities closest to a coordinate, nearest
+// first, with great-circle distances. IDs and names can both be passed
+// straight back as /weather?city=.
+func NearestCities(lat, lon float64, n int) ([]locations.Nearby, error) {
+	if math.IsNaN(lat) || math.IsNaN(lon) || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil, ErrInvalidCoordinates
+	}
+
+	snap, err := citySnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return snap.Index.Nearest(lat, lon, n), nil
+}
+
+// nearestCity returns the closest known city within NearestCityRadiusKm of
+// a coordinate. The gazetteer is optional here: if it can't be read, we
+// simply don't name the location.
+func nearestCity(lat, lon float64) (locations.Location, bool) {
+	snap, err := citySnapshot()
+	if err != nil {
+		return locations.Location{}, false
+	}
+
+	nearest := snap.Index.Nearest(lat, lon, 1)
+	if len(nearest) == 0 || nearest[0].DistanceKm > NearestCityRadiusKm {
+		return locations.Location{}, false
+	}
+	return nearest[0].Location, true
+}
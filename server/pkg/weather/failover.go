@@ -0,0 +1,266 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	"weather-cli/server/pkg/logging"
+	"weather-cli/server/pkg/tracing"
+)
+
+// Defaults for NewFailover.
+const (
+	// DefaultAttemptTimeout bounds a single provider call, so one slow upstream
+	// can't eat the whole request budget before we try the next one.
+	DefaultAttemptTimeout = 4 * time.Second
+	// DefaultBreakerThreshold is how many consecutive failures open a breaker.
+	DefaultBreakerThreshold = 3
+	// DefaultBreakerCooldown is how long an open breaker waits before half-opening.
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrNoProviderAvailable is returned when every provider's breaker is open,
+// so nothing was even attempted.
+var ErrNoProviderAvailable = errors.New("no weather provider available")
+
+// UpstreamStatusError is returned by HTTP providers for non-200 responses.
+type UpstreamStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream error fetching weather data %d: %s", e.StatusCode, e.Body)
+}
+
+// ProviderStatus is the health of one provider, as shown on /diagnostics/providers.
+type ProviderStatus struct {
+	Name                string       `json:"name"`
+	Priority            int          `json:"priority"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+	Calls               int64        `json:"calls"`
+	Failures            int64        `json:"failures"`
+	Skipped             int64        `json:"skipped"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastFailure         *time.Time   `json:"last_failure,omitempty"`
+	LastSuccess         *time.Time   `json:"last_success,omitempty"`
+	LastLatencyMs       int64        `json:"last_latency_ms"`
+}
+
+// member is one provider in a Failover, guarded by its own breaker.
+type member struct {
+	Provider
+	breaker *Breaker
+
+	mu          sync.Mutex
+	calls       int64
+	failures    int64
+	skipped     int64
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+	lastLatency time.Duration
+}
+
+// Failover is a Provider that tries an ordered list of providers, moving to the
+// next one when a provider fails or its circuit breaker is open.
+// Each provider has its own breaker, so one bad upstream never blocks the others.
+type Failover struct {
+	// AttemptTimeout bounds each individual provider call.
+	AttemptTimeout time.Duration
+
+	members []*member
+}
+
+// NewFailover wraps providers (highest priority first) with default breaker settings.
+func NewFailover(providers ...Provider) *Failover {
+	return NewFailoverWithBreakers(DefaultBreakerThreshold, DefaultBreakerCooldown, providers...)
+}
+
+// NewFailoverWithBreakers is NewFailover with custom breaker settings.
+func NewFailoverWithBreakers(threshold int, cooldown time.Duration, providers ...Provider) *Failover {
+	f := &Failover{AttemptTimeout: DefaultAttemptTimeout}
+	for _, p := range providers {
+		f.members = append(f.members, &member{Provider: p, breaker: NewBreaker(threshold, cooldown)})
+	}
+	return f
+}
+
+// Name implements Provider.
+func (f *Failover) Name() string {
+	return "failover"
+}
+
+// Current implements Provider.
+func (f *Failover) Current(ctx context.Context, lat, lon float64) (WeatherResp, error) {
+	var out WeatherResp
+	err := f.try(ctx, func(ctx context.Context, p Provider) error {
+		var err error
+		out, err = p.Current(ctx, lat, lon)
+		return err
+	})
+	return out, err
+}
+
+// Hourly implements Provider.
+func (f *Failover) Hourly(ctx context.Context, lat, lon float64, hours int) (HourlyForecast, error) {
+	var out HourlyForecast
+	err := f.try(ctx, func(ctx context.Context, p Provider) error {
+		var err error
+		out, err = p.Hourly(ctx, lat, lon, hours)
+		return err
+	})
+	return out, err
+}
+
+// Daily implements Provider.
+func (f *Failover) Daily(ctx context.Context, lat, lon float64, days int) (DailyForecast, error) {
+	var out DailyForecast
+	err := f.try(ctx, func(ctx context.Context, p Provider) error {
+		var err error
+		out, err = p.Daily(ctx, lat, lon, days)
+		return err
+	})
+	return out, err
+}
+
+// try calls fn with each provider in priority order until one succeeds.
+func (f *Failover) try(ctx context.Context, fn func(context.Context, Provider) error) error {
+	var lastErr error
+	for _, m := range f.members {
+		generation, ok := m.breaker.Allow()
+		if !ok {
+			m.recordSkip()
+			continue
+		}
+
+		// One span per attempt, so a failover shows up as a slow failed
+		// attempt followed by the one that answered
+		attemptCtx, span := tracing.Start(ctx, "upstream.attempt", tracing.String("provider", m.Name()))
+		attemptCtx, cancel := context.WithTimeout(attemptCtx, f.AttemptTimeout)
+		start := time.Now()
+		err := fn(attemptCtx, m.Provider)
+		cancel()
+		elapsed := time.Since(start)
+
+		if err == nil {
+			m.breaker.Success(generation)
+			m.recordSuccess(elapsed)
+			span.SetAttributes(tracing.String("result", "ok"))
+			span.End()
+			slog.InfoContext(ctx, "upstream call", "provider", m.Name(), logging.Duration(elapsed))
+			return nil
+		}
+		span.RecordError(err)
+
+		// If the caller gave up, the provider didn't necessarily do anything wrong
+		if ctx.Err() != nil {
+			m.breaker.Release(generation)
+			observeUpstream(m.Name(), "canceled", elapsed)
+			span.SetAttributes(tracing.String("result", "canceled"))
+			span.End()
+			slog.InfoContext(ctx, "upstream call canceled", "provider", m.Name(), logging.Duration(elapsed), "err", err)
+			return err
+		}
+
+		if isProviderFault(err) {
+			m.breaker.Failure(generation)
+		} else {
+			m.breaker.Release(generation)
+		}
+		m.recordFailure(err, elapsed)
+		span.SetAttributes(tracing.String("result", "error"))
+		span.End()
+		slog.WarnContext(ctx, "upstream call failed", "provider", m.Name(), logging.Duration(elapsed), "err", err)
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return ErrNoProviderAvailable
+	}
+	return lastErr
+}
+
+// isProviderFault reports whether err should count against a provider's breaker.
+// Timeouts, connection errors, 5xx/429 and decode errors do; other 4xx responses
+// mean we sent a bad request, which another attempt won't fix either.
+func isProviderFault(err error) bool {
+	var statusErr *UpstreamStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// Status returns the health of every provider, in priority order.
+func (f *Failover) Status() []ProviderStatus {
+	out := make([]ProviderStatus, 0, len(f.members))
+	for i, m := range f.members {
+		state, failures, retryAt := m.breaker.snapshot()
+
+		m.mu.Lock()
+		s := ProviderStatus{
+			Name:                m.Name(),
+			Priority:            i + 1,
+			State:               state,
+			ConsecutiveFailures: failures,
+			RetryAt:             timePtr(retryAt),
+			Calls:               m.calls,
+			Failures:            m.failures,
+			Skipped:             m.skipped,
+			LastError:           m.lastError,
+			LastFailure:         timePtr(m.lastFailure),
+			LastSuccess:         timePtr(m.lastSuccess),
+			LastLatencyMs:       m.lastLatency.Milliseconds(),
+		}
+		m.mu.Unlock()
+
+		out = append(out, s)
+	}
+	return out
+}
+
+func (m *member) recordSuccess(elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	m.lastSuccess = time.Now()
+	m.lastLatency = elapsed
+	observeUpstream(m.Name(), "ok", elapsed)
+}
+
+func (m *member) recordFailure(err error, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	m.failures++
+	m.lastError = err.Error()
+	m.lastFailure = time.Now()
+	m.lastLatency = elapsed
+	observeUpstream(m.Name(), "error", elapsed)
+}
+
+func (m *member) recordSkip() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.skipped++
+	upstreamCalls.Inc(m.Name(), "skipped")
+}
+
+// timePtr returns nil for the zero time so it is omitted from JSON.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
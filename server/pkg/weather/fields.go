@@ -0,0 +1,102 @@
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var ErrUnknownField = errors.New("unknown field")
+
+// alwaysIncluded are kept by SelectFields whatever was asked for: without
+// them the selected values can't be read correctly.
+var alwaysIncluded = []string{"units", "stale"}
+
+// weatherFields is the set of JSON field names in a WeatherResp.
+var weatherFields = jsonFieldNames(reflect.TypeOf(WeatherResp{}))
+
+// ParseFields parses a ?fields= value like "temperature,wind_speed" into
+// the list of WeatherResp fields to return. An empty value means all fields
+// (nil). Unknown names return ErrUnknownField.
+func ParseFields(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !weatherFields[f] {
+			return nil, fmt.Errorf("%w %q, expected some of: %s", ErrUnknownField, f, strings.Join(sortedKeys(weatherFields), ", "))
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// SelectFields returns resp with only the given top-level fields (plus units
+// and the stale flag), ready to encode. nil fields returns resp unchanged.
+func SelectFields(resp WeatherResp, fields []string) (any, error) {
+	if fields == nil {
+		return resp, nil
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields)+len(alwaysIncluded))
+	for _, f := range slices.Concat(fields, alwaysIncluded) {
+		// Fields left out by omitempty stay out
+		if v, ok := all[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected, nil
+}
+
+// jsonFieldNames collects the JSON names of t's fields, including those of
+// embedded structs (which encoding/json promotes to the top level).
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			for n := range jsonFieldNames(f.Type) {
+				names[n] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
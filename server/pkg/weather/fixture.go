@@ -0,0 +1,88 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Fixture is a Provider that serves recorded Open-Meteo responses from a
+// local directory, so the server can run fully offline (CI, demos, dev).
+//
+// For each kind of data it looks for a coordinate-specific file first and
+// falls back to a shared one:
+//
+//	<dir>/current_13.08_80.27.json  ->  <dir>/current.json
+//	<dir>/hourly_13.08_80.27.json   ->  <dir>/hourly.json
+//	<dir>/daily_13.08_80.27.json    ->  <dir>/daily.json
+//
+// Coordinates are formatted with two decimals. Files use exactly the JSON
+// Open-Meteo returns, so recording a new fixture is just saving a curl response.
+type Fixture struct {
+	Dir string
+}
+
+// NewFixture returns a provider reading fixtures from dir.
+func NewFixture(dir string) *Fixture {
+	return &Fixture{Dir: dir}
+}
+
+// Name implements Provider.
+func (f *Fixture) Name() string {
+	return "fixture"
+}
+
+// Current implements Provider.
+func (f *Fixture) Current(ctx context.Context, lat, lon float64) (WeatherResp, error) {
+	file, err := f.open("current", lat, lon)
+	if err != nil {
+		return WeatherResp{}, err
+	}
+	defer file.Close()
+
+	return decodeCurrent(file)
+}
+
+// Hourly implements Provider.
+func (f *Fixture) Hourly(ctx context.Context, lat, lon float64, hours int) (HourlyForecast, error) {
+	file, err := f.open("hourly", lat, lon)
+	if err != nil {
+		return HourlyForecast{}, err
+	}
+	defer file.Close()
+
+	return decodeHourly(file, hours)
+}
+
+// Daily implements Provider.
+func (f *Fixture) Daily(ctx context.Context, lat, lon float64, days int) (DailyForecast, error) {
+	file, err := f.open("daily", lat, lon)
+	if err != nil {
+		return DailyForecast{}, err
+	}
+	defer file.Close()
+
+	return decodeDaily(file, days)
+}
+
+// open finds the fixture file for a kind of data at a coordinate.
+func (f *Fixture) open(kind string, lat, lon float64) (io.ReadCloser, error) {
+	paths := []string{
+		filepath.Join(f.Dir, fmt.Sprintf("%s_%.2f_%.2f.json", kind, lat, lon)),
+		filepath.Join(f.Dir, kind+".json"),
+	}
+
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("fixture %s: %w", p, err)
+		}
+	}
+
+	return nil, fmt.Errorf("no %s fixture in %s", kind, f.Dir)
+}
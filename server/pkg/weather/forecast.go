@@ -0,0 +1,234 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"weather-cli/server/pkg/cache"
+)
+
+// DefaultHourlyHours is how many hours GetHourlyForecast returns when the
+// caller doesn't ask for a specific number (the dashboard's "next 24h" strip).
+const DefaultHourlyHours = 24
+
+// MaxHourlyHours is the longest hourly window we serve (7 days).
+// We always fetch the same hours upstream (see hourlyFetchHours) and slice
+// per request, so every window size for a city shares one cache entry.
+const MaxHourlyHours = 168
+
+// hourlyFetchHours is how many hours to fetch for a cache entry. A cached
+// entry is served until its stale TTL runs out, and by then that many hours
+// have passed and are dropped, so fetch enough extra hours (plus one for the
+// hour in progress) that it can still fill a MaxHourlyHours window. Capped at
+// the 16 days Open-Meteo forecasts.
+func hourlyFetchHours() int {
+	_, stale := cache.TTLs(cache.NamespaceHourly)
+	extra := int((stale+time.Hour-1)/time.Hour) + 1
+	return min(MaxHourlyHours+extra, MaxDailyDays*24)
+}
+
+// DefaultDailyDays is how many days GetDailyForecast returns by default (a week ahead).
+const DefaultDailyDays = 7
+
+// MaxDailyDays is the longest daily window Open-Meteo offers.
+// Like hourly data, we always fetch the full window and slice per request.
+const MaxDailyDays = 16
+
+var (
+	ErrInvalidHours = fmt.Errorf("hours must be between 1 and %d", MaxHourlyHours)
+	ErrInvalidDays  = fmt.Errorf("days must be between 1 and %d", MaxDailyDays)
+)
+
+// HourlyPoint is a single hour of forecast data.
+type HourlyPoint struct {
+	Time                     string  `json:"time"`
+	Temperature              float64 `json:"temperature"`
+	FeelsLike                float64 `json:"apparent_temperature"`
+	PrecipitationProbability float64 `json:"precipitation_probability"`
+	Rain                     float64 `json:"rain"`
+	WindSpeed                float64 `json:"wind_speed"`
+	WeatherCode              int     `json:"weather_code"`
+	Description              string  `json:"description"`
+	Category                 string  `json:"category"`
+	Icon                     string  `json:"icon"`
+	IsDay                    int     `json:"is_day"`
+}
+
+// HourlyForecast is the response for /forecast/hourly.
+// Hour times are in the city's local time zone.
+type HourlyForecast struct {
+	City             string        `json:"city"`
+	Timezone         string        `json:"timezone,omitempty"`
+	UTCOffsetSeconds int           `json:"utc_offset_seconds"`
+	Lat              float64       `json:"lat,omitempty"`
+	Lon              float64       `json:"lon,omitempty"`
+	Units            UnitLabels    `json:"units"`
+	Hours            []HourlyPoint `json:"hours"`
+	Freshness
+}
+
+// GetHourlyForecast looks up coordinates for the city and returns the next
+// `hours` hourly points starting from the current hour.
+// It shares the city lookup and cache client with GetWeather; entries live in
+// the hourly cache namespace so they never collide with current weather.
+func GetHourlyForecast(ctx context.Context, city string, hours int) (HourlyForecast, error) {
+	if strings.TrimSpace(city) == "" {
+		return HourlyForecast{}, fmt.Errorf("city name is required")
+	}
+	if hours < 1 || hours > MaxHourlyHours {
+		return HourlyForecast{}, ErrInvalidHours
+	}
+	id, loc, err := lookupCity(ctx, city)
+	if err != nil {
+		return HourlyForecast{}, err
+	}
+	lat, lon := loc.Latitude, loc.Longitude
+
+	// Compute timestamp once to ensure Get and Set use the same cache key
+	now := time.Now()
+
+	key := cacheKeyer.Key(id, lat, lon)
+	out, err := loadThrough(ctx, cache.NamespaceHourly, key, now, func(ctx context.Context) (HourlyForecast, error) {
+		out, err := provider.Hourly(ctx, lat, lon, hourlyFetchHours())
+		if err != nil {
+			return HourlyForecast{}, err
+		}
+		return out, nil
+	})
+	if err != nil {
+		return HourlyForecast{}, err
+	}
+
+	// A cached entry can be up to a stale TTL old, so drop the hours that have
+	// already passed (unless the data ends before now - recorded fixtures may
+	// be from any date). Times are local "2006-01-02T15:04", so they compare as strings.
+	thisHour := now.In(time.FixedZone("", out.UTCOffsetSeconds)).Format("2006-01-02T15") + ":00"
+	if n := len(out.Hours); n > 0 && out.Hours[n-1].Time >= thisHour {
+		for i, h := range out.Hours {
+			if h.Time >= thisHour {
+				out.Hours = out.Hours[i:]
+				break
+			}
+		}
+	}
+
+	// Report the city's own name and coordinates (a shared grid cell's entry
+	// may have been fetched for another city), and trim to the requested window
+	locale := localeFrom(ctx)
+	out.City = loc.DisplayName(locale)
+	out.Timezone = loc.Timezone
+	out.Lat, out.Lon = lat, lon
+	if len(out.Hours) > hours {
+		out.Hours = out.Hours[:hours]
+	}
+	// Coalesced callers share the fetched slice, so convert and describe a copy
+	out.Hours = slices.Clone(out.Hours)
+	out.inUnits(unitsFrom(ctx))
+
+	codes := loadWeatherCodes(ctx, locale)
+	for i := range out.Hours {
+		h := &out.Hours[i]
+		code := codes.Get(h.WeatherCode)
+		h.Description = code.Description
+		h.Category = string(code.Category)
+		h.Icon = code.Icon(h.IsDay == 1)
+	}
+	return out, nil
+}
+
+// DailyPoint is a single day of forecast data.
+// Date, Sunrise and Sunset are in the city's local time zone.
+type DailyPoint struct {
+	Date             string  `json:"date"`
+	TemperatureMax   float64 `json:"temperature_max"`
+	TemperatureMin   float64 `json:"temperature_min"`
+	PrecipitationSum float64 `json:"precipitation_sum"`
+	WindSpeedMax     float64 `json:"wind_speed_max"`
+	WeatherCode      int     `json:"weather_code"`
+	Description      string  `json:"description"`
+	Category         string  `json:"category"`
+	Icon             string  `json:"icon"`
+	Sunrise          string  `json:"sunrise"`
+	Sunset           string  `json:"sunset"`
+	UVIndexMax       float64 `json:"uv_index_max"`
+}
+
+// DailyForecast is the response for /forecast/daily.
+type DailyForecast struct {
+	City             string       `json:"city"`
+	Lat              float64      `json:"lat,omitempty"`
+	Lon              float64      `json:"lon,omitempty"`
+	Timezone         string       `json:"timezone,omitempty"`
+	UTCOffsetSeconds int          `json:"utc_offset_seconds"`
+	Units            UnitLabels   `json:"units"`
+	Days             []DailyPoint `json:"days"`
+	Freshness
+}
+
+// GetDailyForecast looks up coordinates for the city and returns `days`
+// daily summaries starting from today (in the city's time zone).
+// Entries are cached in the daily namespace, which keeps them for hours
+// rather than the 15 minutes used for current weather.
+func GetDailyForecast(ctx context.Context, city string, days int) (DailyForecast, error) {
+	if strings.TrimSpace(city) == "" {
+		return DailyForecast{}, fmt.Errorf("city name is required")
+	}
+	if days < 1 || days > MaxDailyDays {
+		return DailyForecast{}, ErrInvalidDays
+	}
+	id, loc, err := lookupCity(ctx, city)
+	if err != nil {
+		return DailyForecast{}, err
+	}
+	lat, lon := loc.Latitude, loc.Longitude
+
+	// Compute timestamp once to ensure Get and Set use the same cache key
+	now := time.Now()
+
+	key := cacheKeyer.Key(id, lat, lon)
+	out, err := loadThrough(ctx, cache.NamespaceDaily, key, now, func(ctx context.Context) (DailyForecast, error) {
+		out, err := provider.Daily(ctx, lat, lon, MaxDailyDays)
+		if err != nil {
+			return DailyForecast{}, err
+		}
+		return out, nil
+	})
+	if err != nil {
+		return DailyForecast{}, err
+	}
+
+	// A cached entry can outlive local midnight, so start the window at today
+	// (if today is in the data at all - recorded fixtures may be from any date)
+	today := now.In(time.FixedZone(out.Timezone, out.UTCOffsetSeconds)).Format("2006-01-02")
+	for i, d := range out.Days {
+		if d.Date == today {
+			out.Days = out.Days[i:]
+			break
+		}
+	}
+
+	// Report the city's own name and coordinates (a shared grid cell's entry
+	// may have been fetched for another city), and trim to the requested window
+	locale := localeFrom(ctx)
+	out.City = loc.DisplayName(locale)
+	out.Lat, out.Lon = lat, lon
+	if len(out.Days) > days {
+		out.Days = out.Days[:days]
+	}
+	// Coalesced callers share the fetched slice, so convert and describe a copy
+	out.Days = slices.Clone(out.Days)
+	out.inUnits(unitsFrom(ctx))
+
+	codes := loadWeatherCodes(ctx, locale)
+	for i := range out.Days {
+		d := &out.Days[i]
+		code := codes.Get(d.WeatherCode)
+		d.Description = code.Description
+		d.Category = string(code.Category)
+		// A day's summary always uses the daytime icon
+		d.Icon = code.IconDay
+	}
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package weather
+
+import (
+	"fmt"
+	"sync"
+
+	"example.com/locations"
+)
+
+// gazetteer is the city list behind every lookup. It is parsed once and
+// replaced atomically on reload, so requests never read cities.json from disk.
+var (
+	gazetteerMu sync.Mutex
+	gazetteer   *locations.Store
+)
+
+// SetGazetteer configures the city list used for lookups, search and
+// nearest-city queries. Reloading the store takes effect immediately.
+func SetGazetteer(s *locations.Store) {
+	gazetteerMu.Lock()
+	defer gazetteerMu.Unlock()
+
+	gazetteer = s
+}
+
+// citySnapshot returns the current gazetteer. If none was configured with
+// SetGazetteer, the built-in city list is used.
+func citySnapshot() (*locations.Snapshot, error) {
+	gazetteerMu.Lock()
+	defer gazetteerMu.Unlock()
+
+	if gazetteer == nil {
+		s, err := locations.NewStore("")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCitiesUnavailable, err)
+		}
+		gazetteer = s
+	}
+	return gazetteer.Current(), nil
+}
@@ -0,0 +1,25 @@
+package weather
+
+import (
+	"context"
+
+	weather_codes "example.com/weather_codes"
+)
+
+// localeKey is the context key for the response locale.
+type localeKey struct{}
+
+// WithLocale returns a context asking for descriptions and city names in
+// locale (e.g. "hi", "ta"). Responses are cached once, in English, and
+// translated on the way out, so the locale never splits the cache.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// localeFrom returns the locale set with WithLocale, or the default.
+func localeFrom(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return weather_codes.DefaultLocale
+}
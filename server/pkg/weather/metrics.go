@@ -0,0 +1,24 @@
+package weather
+
+import (
+	"time"
+	"weather-cli/server/pkg/metrics"
+)
+
+// Upstream and lookup metrics, served by the server's /metrics endpoint.
+var (
+	upstreamCalls = metrics.NewCounterVec("weather_upstream_requests_total",
+		"Calls to upstream weather providers by provider and result (ok, error, canceled, skipped by an open breaker).",
+		"provider", "result")
+	upstreamDuration = metrics.NewHistogramVec("weather_upstream_request_duration_seconds",
+		"Latency of upstream weather provider calls, by provider and result.",
+		metrics.DefBuckets, "provider", "result")
+	cityNotFound = metrics.NewCounterVec("weather_city_not_found_total",
+		"City names that matched no known city.")
+)
+
+// observeUpstream records one finished upstream call.
+func observeUpstream(provider, result string, elapsed time.Duration) {
+	upstreamCalls.Inc(provider, result)
+	upstreamDuration.Observe(elapsed.Seconds(), provider, result)
+}
@@ -0,0 +1,146 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"weather-cli/server/pkg/tracing"
+
+	"example.com/openmeteo"
+)
+
+// OpenMeteoURL is the public Open-Meteo forecast endpoint.
+const OpenMeteoURL = openmeteo.ForecastURL
+
+// DefaultHTTPTimeout bounds a whole upstream HTTP call, body included.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// Reusable HTTP client with a timeout (DefaultHTTPTimeout unless SetHTTPTimeout is called).
+// Saves resources vs creating new clients for every request,
+// and avoids hanging forever if the API is slow.
+var httpClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// SetHTTPTimeout configures the timeout of the shared upstream HTTP client,
+// used by every OpenMeteo provider without a Client of its own.
+// Values <= 0 restore the default. Call it before serving requests.
+func SetHTTPTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultHTTPTimeout
+	}
+	httpClient.Timeout = d
+}
+
+// OpenMeteo is a Provider backed by the Open-Meteo forecast API
+// (or any server that speaks the same query format).
+type OpenMeteo struct {
+	// BaseURL is the forecast endpoint, e.g. OpenMeteoURL or a self-hosted mirror.
+	BaseURL string
+	// Client is the HTTP client used for upstream calls.
+	Client *http.Client
+}
+
+// NewOpenMeteo returns a provider for the public Open-Meteo API.
+func NewOpenMeteo() *OpenMeteo {
+	return &OpenMeteo{BaseURL: OpenMeteoURL, Client: httpClient}
+}
+
+// Name implements Provider.
+// Mirrors are named after their host so they can be told apart in diagnostics.
+func (o *OpenMeteo) Name() string {
+	if o.BaseURL == OpenMeteoURL {
+		return "open-meteo"
+	}
+	if u, err := url.Parse(o.BaseURL); err == nil && u.Host != "" {
+		return "open-meteo@" + u.Host
+	}
+	return "open-meteo@" + o.BaseURL
+}
+
+// Current implements Provider.
+func (o *OpenMeteo) Current(ctx context.Context, lat, lon float64) (WeatherResp, error) {
+	body, err := o.get(ctx, openmeteo.CurrentURL(o.BaseURL, lat, lon))
+	if err != nil {
+		return WeatherResp{}, err
+	}
+	defer body.Close()
+
+	return decodeCurrent(body)
+}
+
+// Hourly implements Provider.
+func (o *OpenMeteo) Hourly(ctx context.Context, lat, lon float64, hours int) (HourlyForecast, error) {
+	body, err := o.get(ctx, openmeteo.HourlyURL(o.BaseURL, lat, lon, hours))
+	if err != nil {
+		return HourlyForecast{}, err
+	}
+	defer body.Close()
+
+	return decodeHourly(body, hours)
+}
+
+// Daily implements Provider.
+func (o *OpenMeteo) Daily(ctx context.Context, lat, lon float64, days int) (DailyForecast, error) {
+	body, err := o.get(ctx, openmeteo.DailyURL(o.BaseURL, lat, lon, days))
+	if err != nil {
+		return DailyForecast{}, err
+	}
+	defer body.Close()
+
+	return decodeDaily(body, days)
+}
+
+// get performs a GET against the upstream API and returns the body of a 200 response.
+// The caller must close the returned body.
+func (o *OpenMeteo) get(ctx context.Context, reqURL string) (io.ReadCloser, error) {
+	client := o.Client
+	if client == nil {
+		client = httpClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building upstream request: %w", err)
+	}
+
+	// The span covers the whole exchange, body included: it ends when the
+	// caller closes the body after decoding it
+	ctx, span := tracing.StartClient(ctx, "GET "+req.URL.Host,
+		tracing.String("http.method", http.MethodGet), tracing.String("server.address", req.URL.Host), tracing.String("url.path", req.URL.Path))
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("upstream request failed: %w", err)
+		span.RecordError(err)
+		span.End()
+		return nil, err
+	}
+	span.SetAttributes(tracing.Int("http.status_code", resp.StatusCode))
+
+	// if upstream returns non-200, capture body to help debugging
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		defer span.End()
+		body, _ := io.ReadAll(resp.Body)
+		err := &UpstreamStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+		span.RecordError(err)
+		return nil, err
+	}
+
+	return spanBody{resp.Body, span}, nil
+}
+
+// spanBody ends an upstream request's span when its body is closed.
+type spanBody struct {
+	io.ReadCloser
+	span *tracing.Span
+}
+
+func (b spanBody) Close() error {
+	defer b.span.End()
+	return b.ReadCloser.Close()
+}
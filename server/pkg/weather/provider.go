@@ -0,0 +1,225 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"example.com/openmeteo"
+)
+
+// Provider fetches weather data for a coordinate from one upstream source.
+// Implementations only deal with raw numbers: city names, descriptions and
+// caching are handled by GetWeather / GetHourlyForecast / GetDailyForecast.
+type Provider interface {
+	// Name identifies the provider in logs and diagnostics.
+	Name() string
+	// Current returns a snapshot of the current conditions.
+	Current(ctx context.Context, lat, lon float64) (WeatherResp, error)
+	// Hourly returns up to `hours` hourly points starting from the current hour.
+	Hourly(ctx context.Context, lat, lon float64, hours int) (HourlyForecast, error)
+	// Daily returns up to `days` daily summaries starting from today.
+	Daily(ctx context.Context, lat, lon float64, days int) (DailyForecast, error)
+}
+
+// provider is the upstream used by the Get* functions: a Failover over the
+// configured providers. Defaults to the public Open-Meteo API alone.
+var provider = NewFailover(NewOpenMeteo())
+
+// SetProviders configures the upstream weather sources in priority order.
+// Each call is tried against the first provider whose circuit breaker is closed,
+// falling through to the next one on failure.
+// Pass nothing to restore the default Open-Meteo provider.
+func SetProviders(providers ...Provider) {
+	SetFailover(NewFailover(providers...))
+}
+
+// SetFailover is SetProviders with a pre-built Failover, for custom breaker settings.
+func SetFailover(f *Failover) {
+	if f == nil || len(f.members) == 0 {
+		f = NewFailover(NewOpenMeteo())
+	}
+	provider = f
+}
+
+// ProvidersStatus reports the health and breaker state of every configured provider.
+func ProvidersStatus() []ProviderStatus {
+	return provider.Status()
+}
+
+// The Open-Meteo JSON shapes below are shared by every provider that speaks
+// Open-Meteo's format (the live API and the on-disk fixtures).
+
+// openMeteoCurrent mirrors the "current" block requested by openmeteo.CurrentParams.
+type openMeteoCurrent struct {
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	Generationtime float64 `json:"generationtime_ms"`
+	Current        struct {
+		Temperature              float64 `json:"temperature_2m"`
+		WeatherCode              int     `json:"weather_code"`
+		Time                     string  `json:"time"`
+		Humidity                 float64 `json:"relative_humidity_2m"`
+		Rain                     float64 `json:"rain"`
+		PrecipitationProbability float64 `json:"precipitation_probability"`
+		IsDay                    int     `json:"is_day"`
+		FeelsLike                float64 `json:"apparent_temperature"`
+		WindSpeed                float64 `json:"wind_speed_10m"`
+		WindGusts                float64 `json:"wind_gusts_10m"`
+		WindDirection            float64 `json:"wind_direction_10m"`
+		Pressure                 float64 `json:"surface_pressure"`
+		CloudCover               float64 `json:"cloud_cover"`
+		Visibility               float64 `json:"visibility"`
+		DewPoint                 float64 `json:"dew_point_2m"`
+		UVIndex                  float64 `json:"uv_index"`
+	} `json:"current"`
+}
+
+// openMeteoHourly mirrors the "hourly" block requested by openmeteo.HourlyParams.
+// Open-Meteo returns hourly data as parallel arrays, one per variable.
+type openMeteoHourly struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	UTCOffsetSeconds int     `json:"utc_offset_seconds"`
+	Hourly           struct {
+		Time                     []string  `json:"time"`
+		Temperature              []float64 `json:"temperature_2m"`
+		FeelsLike                []float64 `json:"apparent_temperature"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+		Rain                     []float64 `json:"rain"`
+		WeatherCode              []int     `json:"weather_code"`
+		IsDay                    []int     `json:"is_day"`
+		WindSpeed                []float64 `json:"wind_speed_10m"`
+	} `json:"hourly"`
+}
+
+// openMeteoDaily mirrors the "daily" block requested by openmeteo.DailyParams.
+type openMeteoDaily struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	Timezone         string  `json:"timezone"`
+	UTCOffsetSeconds int     `json:"utc_offset_seconds"`
+	Daily            struct {
+		Time             []string  `json:"time"`
+		TempMax          []float64 `json:"temperature_2m_max"`
+		TempMin          []float64 `json:"temperature_2m_min"`
+		PrecipitationSum []float64 `json:"precipitation_sum"`
+		WeatherCode      []int     `json:"weather_code"`
+		Sunrise          []string  `json:"sunrise"`
+		Sunset           []string  `json:"sunset"`
+		UVIndexMax       []float64 `json:"uv_index_max"`
+		WindSpeedMax     []float64 `json:"wind_speed_10m_max"`
+	} `json:"daily"`
+}
+
+// decodeCurrent parses an Open-Meteo "current" response.
+func decodeCurrent(r io.Reader) (WeatherResp, error) {
+	var raw openMeteoCurrent
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return WeatherResp{}, fmt.Errorf("decode failed: %w", err)
+	}
+
+	return WeatherResp{
+		Temperature:              raw.Current.Temperature,
+		Timestamp:                raw.Current.Time,
+		Lat:                      raw.Latitude,
+		Lon:                      raw.Longitude,
+		Humidity:                 raw.Current.Humidity,
+		Rain:                     raw.Current.Rain,
+		PrecipitationProbability: raw.Current.PrecipitationProbability,
+		WeatherCode:              raw.Current.WeatherCode,
+		IsDay:                    raw.Current.IsDay,
+		FeelsLike:                raw.Current.FeelsLike,
+		WindSpeed:                raw.Current.WindSpeed,
+		WindGusts:                raw.Current.WindGusts,
+		WindDirection:            raw.Current.WindDirection,
+		WindDirectionCompass:     openmeteo.CompassPoint(raw.Current.WindDirection),
+		Pressure:                 raw.Current.Pressure,
+		CloudCover:               raw.Current.CloudCover,
+		Visibility:               raw.Current.Visibility,
+		DewPoint:                 raw.Current.DewPoint,
+		UVIndex:                  raw.Current.UVIndex,
+	}, nil
+}
+
+// decodeHourly parses an Open-Meteo "hourly" response, keeping at most `hours` points.
+func decodeHourly(r io.Reader, hours int) (HourlyForecast, error) {
+	var raw openMeteoHourly
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return HourlyForecast{}, fmt.Errorf("decode failed: %w", err)
+	}
+
+	h := raw.Hourly
+	n := len(h.Time)
+	if len(h.Temperature) != n || len(h.FeelsLike) != n || len(h.PrecipitationProbability) != n ||
+		len(h.Rain) != n || len(h.WeatherCode) != n || len(h.IsDay) != n || len(h.WindSpeed) != n {
+		return HourlyForecast{}, fmt.Errorf("decode failed: hourly arrays have mismatched lengths")
+	}
+	if n > hours {
+		n = hours
+	}
+
+	points := make([]HourlyPoint, n)
+	for i := range points {
+		points[i] = HourlyPoint{
+			Time:                     h.Time[i],
+			Temperature:              h.Temperature[i],
+			FeelsLike:                h.FeelsLike[i],
+			PrecipitationProbability: h.PrecipitationProbability[i],
+			Rain:                     h.Rain[i],
+			WeatherCode:              h.WeatherCode[i],
+			IsDay:                    h.IsDay[i],
+			WindSpeed:                h.WindSpeed[i],
+		}
+	}
+
+	return HourlyForecast{
+		Lat:              raw.Latitude,
+		Lon:              raw.Longitude,
+		UTCOffsetSeconds: raw.UTCOffsetSeconds,
+		Hours:            points,
+	}, nil
+}
+
+// decodeDaily parses an Open-Meteo "daily" response, keeping at most `days` points.
+func decodeDaily(r io.Reader, days int) (DailyForecast, error) {
+	var raw openMeteoDaily
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return DailyForecast{}, fmt.Errorf("decode failed: %w", err)
+	}
+
+	d := raw.Daily
+	n := len(d.Time)
+	if len(d.TempMax) != n || len(d.TempMin) != n || len(d.PrecipitationSum) != n ||
+		len(d.WeatherCode) != n || len(d.Sunrise) != n || len(d.Sunset) != n || len(d.UVIndexMax) != n ||
+		len(d.WindSpeedMax) != n {
+		return DailyForecast{}, fmt.Errorf("decode failed: daily arrays have mismatched lengths")
+	}
+	if n > days {
+		n = days
+	}
+
+	points := make([]DailyPoint, n)
+	for i := range points {
+		points[i] = DailyPoint{
+			Date:             d.Time[i],
+			TemperatureMax:   d.TempMax[i],
+			TemperatureMin:   d.TempMin[i],
+			PrecipitationSum: d.PrecipitationSum[i],
+			WindSpeedMax:     d.WindSpeedMax[i],
+			WeatherCode:      d.WeatherCode[i],
+			Sunrise:          d.Sunrise[i],
+			Sunset:           d.Sunset[i],
+			UVIndexMax:       d.UVIndexMax[i],
+		}
+	}
+
+	return DailyForecast{
+		Lat:              raw.Latitude,
+		Lon:              raw.Longitude,
+		Timezone:         raw.Timezone,
+		UTCOffsetSeconds: raw.UTCOffsetSeconds,
+		Days:             points,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package weather
+
+import (
+	"fmt"
+
+	"example.com/locations"
+)
+
+// Search limits.
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 50
+	// maxSuggestions is how many "did you mean" names a CityNotFoundError carries.
+	maxSuggestions = 5
+)
+
+// CityNotFoundError is returned when a city isn't in the gazetteer.
+// It matches ErrCityNotFound with errors.Is, and carries the closest
+// names so clients can offer "did you mean ...?".
+type CityNotFoundError struct {
+	City        string
+	Suggestions []string
+}
+
+func (e *CityNotFoundError) Error() string {
+	return fmt.Sprintf("%v: %q", ErrCityNotFound, e.City)
+}
+
+func (e *CityNotFoundError) Unwrap() error {
+	return ErrCityNotFound
+}
+
+// SearchCities returns up to limit known cities matching q, best match first.
+// Matching is fuzzy: prefixes, word prefixes and small typos all count.
+func SearchCities(q string, limit int) ([]locations.Match, error) {
+	snap, err := citySnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := locations.Search(snap.Gazetteer.Cities, q, limit)
+	if matches == nil {
+		matches = []locations.Match{}
+	}
+	return matches, nil
+}
+
+// suggestCities returns the display names of the closest matches for an unknown city.
+func suggestCities(g *locations.Gazetteer, city string) []string {
+	names := []string{}
+	for _, m := range locations.Search(g.Cities, city, maxSuggestions) {
+		names = append(names, m.Name)
+	}
+	return names
+}
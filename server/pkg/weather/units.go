@@ -0,0 +1,137 @@
+package weather
+
+import (
+	"context"
+	"math"
+
+	"example.com/openmeteo"
+)
+
+// Units is the unit each quantity is reported in. Providers and the cache
+// always work in metric; responses are converted on the way out.
+// The type and its parsing live in openmeteo, shared with the CLI.
+type Units = openmeteo.Units
+
+// UnitLabels is the "units" block of a response: the display symbol of
+// each quantity's unit, like Open-Meteo's "current_units".
+type UnitLabels struct {
+	Temperature   string `json:"temperature"`
+	Precipitation string `json:"precipitation"`
+	WindSpeed     string `json:"wind_speed"`
+	// Pressure and visibility are always hPa and meters. Only current
+	// conditions report them.
+	Pressure   string `json:"pressure,omitempty"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// unitSymbols maps each unit identifier to its display symbol.
+var unitSymbols = map[string]string{
+	openmeteo.Celsius:           "°C",
+	openmeteo.Fahrenheit:        "°F",
+	openmeteo.Millimeters:       "mm",
+	openmeteo.Inches:            "inch",
+	openmeteo.KilometersPerHour: "km/h",
+	openmeteo.MetersPerSecond:   "m/s",
+	openmeteo.MilesPerHour:      "mph",
+	openmeteo.Knots:             "kn",
+}
+
+// unitLabels returns the display symbols for u.
+func unitLabels(u Units) UnitLabels {
+	return UnitLabels{
+		Temperature:   unitSymbols[u.Temperature],
+		Precipitation: unitSymbols[u.Precipitation],
+		WindSpeed:     unitSymbols[u.WindSpeed],
+	}
+}
+
+// unitsKey is the context key for the response units.
+type unitsKey struct{}
+
+// WithUnits returns a context asking for responses in u. Like the locale,
+// units are applied after the cache, so every unit system shares one entry.
+func WithUnits(ctx context.Context, u Units) context.Context {
+	return context.WithValue(ctx, unitsKey{}, u)
+}
+
+// unitsFrom returns the units set with WithUnits, or metric.
+func unitsFrom(ctx context.Context) Units {
+	if u, ok := ctx.Value(unitsKey{}).(Units); ok {
+		return u
+	}
+	return openmeteo.MetricUnits
+}
+
+// inUnits converts a metric response to u and labels it.
+func (r *WeatherResp) inUnits(u Units) {
+	r.Temperature = temperature(u, r.Temperature)
+	r.FeelsLike = temperature(u, r.FeelsLike)
+	r.Rain = precipitation(u, r.Rain)
+	r.WindSpeed = windSpeed(u, r.WindSpeed)
+	r.WindGusts = windSpeed(u, r.WindGusts)
+	r.DewPoint = temperature(u, r.DewPoint)
+	r.Units = unitLabels(u)
+	r.Units.Pressure, r.Units.Visibility = "hPa", "m"
+}
+
+// inUnits converts every hour of a metric forecast to u and labels it.
+// The caller must own f.Hours.
+func (f *HourlyForecast) inUnits(u Units) {
+	for i := range f.Hours {
+		h := &f.Hours[i]
+		h.Temperature = temperature(u, h.Temperature)
+		h.FeelsLike = temperature(u, h.FeelsLike)
+		h.Rain = precipitation(u, h.Rain)
+		h.WindSpeed = windSpeed(u, h.WindSpeed)
+	}
+	f.Units = unitLabels(u)
+}
+
+// inUnits converts every day of a metric forecast to u and labels it.
+// The caller must own f.Days.
+func (f *DailyForecast) inUnits(u Units) {
+	for i := range f.Days {
+		d := &f.Days[i]
+		d.TemperatureMax = temperature(u, d.TemperatureMax)
+		d.TemperatureMin = temperature(u, d.TemperatureMin)
+		d.PrecipitationSum = precipitation(u, d.PrecipitationSum)
+		d.WindSpeedMax = windSpeed(u, d.WindSpeedMax)
+	}
+	f.Units = unitLabels(u)
+}
+
+// temperature converts a Celsius value to u's temperature unit.
+func temperature(u Units, c float64) float64 {
+	if u.Temperature == openmeteo.Fahrenheit {
+		return round(c*9/5+32, 1)
+	}
+	return c
+}
+
+// precipitation converts millimeters to u's precipitation unit.
+func precipitation(u Units, mm float64) float64 {
+	if u.Precipitation == openmeteo.Inches {
+		return round(mm/25.4, 2)
+	}
+	return mm
+}
+
+// windSpeed converts km/h to u's wind speed unit.
+func windSpeed(u Units, kmh float64) float64 {
+	switch u.WindSpeed {
+	case openmeteo.MetersPerSecond:
+		return round(kmh/3.6, 1)
+	case openmeteo.MilesPerHour:
+		return round(kmh/1.609344, 1)
+	case openmeteo.Knots:
+		return round(kmh/1.852, 1)
+	}
+	return kmh
+}
+
+// round rounds v to the given number of decimal places, so converted values
+// look like the upstream ones (30.4, not 86.72000000000001).
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
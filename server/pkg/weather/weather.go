@@ -5,142 +5,70 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
-	"path/filepath"
+	"log/slog"
 	"strings"
 	"time"
+	"weather-cli/server/pkg/cache"
+	"weather-cli/server/pkg/tracing"
+
+	"example.com/locations"
+	weather_codes "example.com/weather_codes"
 )
 
 type WeatherResp struct {
 	City                     string  `json:"city"`
-	TempC                    float64 `json:"temp_c"`
+	Country                  string  `json:"country,omitempty"`
+	Timezone                 string  `json:"timezone,omitempty"`
+	Temperature              float64 `json:"temperature"`
 	Description              string  `json:"description"`
 	Timestamp                string  `json:"time"`
 	Lat                      float64 `json:"lat,omitempty"`
 	Lon                      float64 `json:"lon,omitempty"`
 	WeatherCode              int     `json:"weather_code"`
+	Category                 string  `json:"category"`
+	Icon                     string  `json:"icon"`
 	Humidity                 float64 `json:"humidity"`
 	Rain                     float64 `json:"rain"`
 	PrecipitationProbability float64 `json:"precipitation_probability"`
 	IsDay                    int     `json:"is_day"`
 	FeelsLike                float64 `json:"apparent_temperature"`
+	WindSpeed                float64 `json:"wind_speed"`
+	WindGusts                float64 `json:"wind_gusts"`
+	WindDirection            float64 `json:"wind_direction"`         // degrees the wind blows from, 0 = north
+	WindDirectionCompass     string  `json:"wind_direction_compass"` // e.g. "ESE"
+	Pressure                 float64 `json:"surface_pressure"`
+	CloudCover               float64 `json:"cloud_cover"`
+	Visibility               float64 `json:"visibility"`
+	DewPoint                 float64 `json:"dew_point"`
+	UVIndex                  float64 `json:"uv_index"`
+	// Units labels the values above that have a unit
+	Units UnitLabels `json:"units"`
+	Freshness
 }
 
-// Reusable HTTP client with a 10s timeout.
-// Saves resources vs creating new clients for every request,
-// and avoids hanging forever if the API is slow.
-var httpClient = &http.Client{Timeout: 10 * time.Second}
-
-// cityEntry is an internal helper for decoding cities.json.
-// It tolerates different field names (name/city, lat/latitude, lon/longitude).
-// Not exported (lowercase c) because it's only used inside this package.
-type cityEntry struct {
-	Name      string  `json:"name"`
-	City      string  `json:"city"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Lat       float64 `json:"lat"`
-	Lon       float64 `json:"lon"`
-}
-
-// readCities loads locations/cities.json into a map: cityName -> [lat, lon].
-// 1. Always starts with a Chennai fallback (so it never breaks).
-// 2. Tries to parse JSON as array of cityEntry objects.
-// 3. If that fails, tries JSON as object map (name -> coords).
-func readCities() (map[string][2]float64, error) {
-	// try to read locations/cities.json relative to working dir or parent
-	paths := []string{
-		"locations/cities.json",
-		filepath.Join("..", "locations", "cities.json"),
-	}
-
-	var data []byte
-	var lastErr error
-	for _, p := range paths {
-		data, lastErr = os.ReadFile(p)
-		if lastErr == nil && len(data) > 0 {
-			break
-		}
-	}
-
-	if lastErr != nil || len(data) == 0 {
-		return nil, fmt.Errorf("readCities: failed to read locations/cities.json: %w", lastErr)
-	}
-
-	out := make(map[string][2]float64)
-
-	// try array-of-objects first
-	var arr []cityEntry
-	if err := json.Unmarshal(data, &arr); err == nil && len(arr) > 0 {
-		for _, e := range arr {
-			name := strings.ToLower(strings.TrimSpace(firstNonEmpty(e.Name, e.City)))
-			if name == "" {
-				continue
-			}
-			lat := firstNonZero(e.Lat, e.Latitude)
-			lon := firstNonZero(e.Lon, e.Longitude)
-			// only add if we have non-zero coords
-			if lat != 0 || lon != 0 {
-				out[name] = [2]float64{lat, lon}
-			}
-		}
-		if len(out) == 0 {
-			return nil, fmt.Errorf("readCities: parsed file but found no valid entries")
-		}
-		return out, nil
-	}
-
-	// try object mapping: { "city": { "lat": .., "lon": .. }, ... }
-	var obj map[string]map[string]float64
-	if err := json.Unmarshal(data, &obj); err == nil && len(obj) > 0 {
-		for k, v := range obj {
-			lat := v["lat"]
-			if lat == 0 {
-				lat = v["latitude"]
-			}
-			lon := v["lon"]
-			if lon == 0 {
-				lon = v["longitude"]
-			}
-			if lat != 0 || lon != 0 {
-				out[strings.ToLower(strings.TrimSpace(k))] = [2]float64{lat, lon}
-			}
-		}
-		if len(out) == 0 {
-			return nil, fmt.Errorf("readCities: parsed object but found no valid coords")
-		}
-		return out, nil
-	}
-
-	// everything failed
-	return nil, fmt.Errorf("readCities: unsupported JSON structure or empty file")
-}
-
-// firstNonEmpty picks the first non-blank string.
-// Useful for preferring Name over City when decoding cities.json.
-func firstNonEmpty(s1, s2 string) string {
-	if strings.TrimSpace(s1) != "" {
-		return s1
-	}
-	return s2
+// Freshness tells callers how old a response is.
+// It is embedded in every response type so its fields sit at the top level of the JSON.
+type Freshness struct {
+	// Stale is true when the data comes from an expired cache entry, served
+	// because a refresh is still running or the upstream is failing.
+	Stale bool `json:"stale,omitempty"`
+	// FetchedAt is when the data was fetched from the upstream provider.
+	// Not part of the JSON body; handlers turn it into an Age header.
+	FetchedAt time.Time `json:"-"`
 }
 
-// firstNonZero picks the first non-zero float.
-// Useful for preferring Lat over Latitude, Lon over Longitude, etc.
-func firstNonZero(f1, f2 float64) float64 {
-	if f1 != 0 {
-		return f1
-	}
-	return f2
+// setFreshness implements freshnessSetter for every type embedding Freshness.
+func (f *Freshness) setFreshness(fetchedAt time.Time, stale bool) {
+	f.FetchedAt = fetchedAt
+	f.Stale = stale
 }
 
-// GetWeather looks up coordinates for the city, calls Open-Meteo current_weather,
+// GetWeather looks up coordinates for the city, asks the configured Provider
+// (Open-Meteo by default) for current conditions,
 // and returns a sanitized WeatherResp.
 // If a cache client is configured (via SetCacheClient), it will check cache first
-// and store results for 15-minute intervals.
+// and store results for 15-minute intervals. Concurrent cache misses for the same
+// city are coalesced into a single upstream call.
 
 var (
 	ErrCitiesUnavailable = errors.New("cities data unavailable")
@@ -149,8 +77,8 @@ var (
 
 // CacheClient interface defines methods needed for caching weather data
 type CacheClient interface {
-	Get(ctx context.Context, city string, at time.Time) ([]byte, error)
-	Set(ctx context.Context, city string, at time.Time, data []byte) error
+	Get(ctx context.Context, ns cache.Namespace, city string, at time.Time) (*cache.Entry, error)
+	Set(ctx context.Context, ns cache.Namespace, city string, at time.Time, data []byte) error
 }
 
 var cacheClient CacheClient
@@ -161,152 +89,169 @@ func SetCacheClient(client CacheClient) {
 	cacheClient = client
 }
 
+// cacheKeyer names cache entries. Keying on the city name (the default)
+// gives every spelling its own entry; coordinate keyers share one per area.
+var cacheKeyer cache.Keyer = cache.CityKeyer{}
+
+// SetCacheKeyer configures how cache entries are keyed.
+// Pass nil to key on the city name.
+func SetCacheKeyer(k cache.Keyer) {
+	if k == nil {
+		k = cache.CityKeyer{}
+	}
+	cacheKeyer = k
+}
+
 func GetWeather(ctx context.Context, city string) (WeatherResp, error) {
+	ctx, span := tracing.Start(ctx, "weather.GetWeather", tracing.String("city", city))
+	defer span.End()
+
 	if strings.TrimSpace(city) == "" {
 		return WeatherResp{}, fmt.Errorf("city name is required")
 	}
-	cityKey := strings.ToLower(strings.TrimSpace(city))
 
+	id, loc, err := lookupCity(ctx, city)
+	if err != nil {
+		span.RecordError(err)
+		return WeatherResp{}, err
+	}
+
+	resp, err := currentAt(ctx, id, loc)
+	span.RecordError(err)
+	return resp, err
+}
+
+// currentAt returns current conditions at a resolved location, through the cache.
+// loc's display name, country, timezone and coordinates are echoed back in the response;
+// id identifies the location for city-keyed caching.
+func currentAt(ctx context.Context, id string, loc locations.Location) (WeatherResp, error) {
 	// Compute timestamp once to ensure Get and Set use the same cache key
 	now := time.Now()
+	lat, lon := loc.Latitude, loc.Longitude
 
-	// Try cache first if cache client is configured
-	if cacheClient != nil {
-		cached, err := cacheClient.Get(ctx, cityKey, now)
-		if err != nil {
-			// Log cache error but continue to API call
-			log.Printf("Cache get error for %s: %v", cityKey, err)
-		} else if cached != nil {
-			// Cache hit! Unmarshal and return
-			var resp WeatherResp
-			if err := json.Unmarshal(cached, &resp); err == nil {
-				log.Printf("Cache HIT for %s", cityKey)
-				return resp, nil
-			}
-			log.Printf("Cache data unmarshal error for %s: %v", cityKey, err)
-		} else {
-			// Cache miss - continue to API call
-			log.Printf("Cache MISS for %s", cityKey)
-		}
+	// Try cache first; on a miss, concurrent requests for this location share one fetch
+	key := cacheKeyer.Key(id, lat, lon)
+	resp, err := loadThrough(ctx, cache.NamespaceCurrent, key, now, func(ctx context.Context) (WeatherResp, error) {
+		return provider.Current(ctx, lat, lon)
+	})
+	if err != nil {
+		return WeatherResp{}, err
 	}
 
-	cities, err := readCities()
+	// Describe the conditions in the caller's language; the cached copy is the same for all
+	locale := localeFrom(ctx)
+	code := loadWeatherCodes(ctx, locale).Get(resp.WeatherCode)
+	resp.Description = code.Description
+	resp.Category = string(code.Category)
+	resp.Icon = code.Icon(resp.IsDay == 1)
+
+	// The cache holds metric values; convert to what the caller asked for
+	resp.inUnits(unitsFrom(ctx))
+
+	// Cached and shared results may come from another city in the same cell,
+	// so report this location's own name and coordinates
+	resp.City = loc.DisplayName(locale)
+	resp.Country = loc.Country
+	resp.Timezone = loc.Timezone
+	resp.Lat, resp.Lon = lat, lon
+	return resp, nil
+}
+
+// lookupCity resolves a city name or alias to its gazetteer ID and Location.
+// Unknown cities return a *CityNotFoundError with suggestions.
+func lookupCity(ctx context.Context, city string) (string, locations.Location, error) {
+	_, span := tracing.Start(ctx, "gazetteer.lookup", tracing.String("city", city))
+	defer span.End()
+
+	snap, err := citySnapshot()
 	if err != nil {
 		// propagate a clear error; handler will map to 500
-		return WeatherResp{}, fmt.Errorf("%w: %v", ErrCitiesUnavailable, err)
+		span.RecordError(err)
+		return "", locations.Location{}, err
 	}
 
-	// Try exact key first, then a simple heuristic (trim after comma)
-	coords, ok := cities[cityKey]
+	g := snap.Gazetteer
+	id, loc, ok := g.Lookup(city)
+	span.SetAttributes(tracing.Bool("found", ok))
 	if !ok {
-		first := strings.Split(cityKey, ",")[0]
-		if v, ok2 := cities[first]; ok2 {
-			coords = v
-		} else {
-			// Let the caller decide to return 404
-			return WeatherResp{}, ErrCityNotFound
-		}
+		// Let the caller decide to return 404, with a few close names to offer.
+		// Suggestions mean a fuzzy search of every name, so it shows in the span.
+		cityNotFound.Inc()
+		return "", locations.Location{}, &CityNotFoundError{City: city, Suggestions: suggestCities(g, city)}
 	}
+	span.SetAttributes(tracing.String("city.id", id))
+	return id, loc, nil
+}
 
-	lat := coords[0]
-	lon := coords[1]
-
-	// build Open-Meteo URL for current weather with humidity and rain
-	url := fmt.Sprintf("https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&current=temperature_2m,weather_code,relative_humidity_2m,rain,precipitation_probability,is_day,apparent_temperature&timezone=auto", lat, lon)
-
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return WeatherResp{}, fmt.Errorf("upstream request failed: %w", err)
+// cacheGet looks up key in the given cache namespace and unmarshals a hit into v.
+// Returns the entry (which may be stale), or nil on a miss, on any cache error,
+// or when caching is disabled.
+func cacheGet(ctx context.Context, ns cache.Namespace, key string, at time.Time, v any) *cache.Entry {
+	if cacheClient == nil {
+		return nil
 	}
-	defer resp.Body.Close()
 
-	// if upstream returns non-200, capture body to help debugging
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return WeatherResp{}, fmt.Errorf("upstream error fetching weather data %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	entry, err := cacheLookup(ctx, ns, key, at, v)
+	switch {
+	case err != nil:
+		// Log cache error but continue to API call
+		slog.WarnContext(ctx, "cache get failed", "namespace", ns, "key", key, "err", err)
+	case entry == nil:
+		// Cache miss - continue to API call
+		slog.InfoContext(ctx, "cache miss", "namespace", ns, "key", key)
+	case entry.Stale:
+		slog.InfoContext(ctx, "cache stale", "namespace", ns, "key", key, "age_s", int(at.Sub(entry.StoredAt).Seconds()))
+	default:
+		slog.DebugContext(ctx, "cache hit", "namespace", ns, "key", key)
 	}
+	return entry
+}
 
-	var raw struct {
-		Latitude       float64 `json:"latitude"`
-		Longitude      float64 `json:"longitude"`
-		Generationtime float64 `json:"generationtime_ms"`
-		Current        struct {
-			Temperature              float64 `json:"temperature_2m"`
-			WeatherCode              int     `json:"weather_code"`
-			Time                     string  `json:"time"`
-			Humidity                 float64 `json:"relative_humidity_2m"`
-			Rain                     float64 `json:"rain"`
-			PrecipitationProbability float64 `json:"precipitation_probability"`
-			IsDay                    int     `json:"is_day"`
-			FeelsLike                float64 `json:"apparent_temperature"`
-		} `json:"current"`
+// cacheLookup is cacheGet without the logging, for callers that poll the cache.
+func cacheLookup(ctx context.Context, ns cache.Namespace, key string, at time.Time, v any) (*cache.Entry, error) {
+	if cacheClient == nil {
+		return nil, nil
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
-		return WeatherResp{}, fmt.Errorf("decode failed: %w", err)
+	entry, err := cacheClient.Get(ctx, ns, key, at)
+	if err != nil || entry == nil {
+		return nil, err
 	}
 
-	codes := loadWeatherCodes()
-	desc, ok := codes[raw.Current.WeatherCode]
-	if !ok {
-		desc = fmt.Sprintf("Unknown code %d", raw.Current.WeatherCode)
-	}
-	fmt.Printf("Weather: %+v\n", raw)
-
-	out := WeatherResp{
-		City:                     city,
-		TempC:                    raw.Current.Temperature,
-		Description:              desc,
-		Timestamp:                raw.Current.Time,
-		Lat:                      raw.Latitude,
-		Lon:                      raw.Longitude,
-		Humidity:                 raw.Current.Humidity,
-		Rain:                     raw.Current.Rain,
-		PrecipitationProbability: raw.Current.PrecipitationProbability,
-		WeatherCode:              raw.Current.WeatherCode,
-		IsDay:                    raw.Current.IsDay,
-		FeelsLike:                raw.Current.FeelsLike,
+	if err := json.Unmarshal(entry.Data, v); err != nil {
+		return nil, fmt.Errorf("cache data unmarshal: %w", err)
 	}
+	return entry, nil
+}
 
-	// Store in cache if cache client is configured
-	if cacheClient != nil {
-		// Marshal to JSON for caching
-		jsonData, err := json.Marshal(out)
-		if err != nil {
-			log.Printf("Cache marshal error for %s: %v", cityKey, err)
-		} else if err := cacheClient.Set(ctx, cityKey, now, jsonData); err != nil {
-			// Log error but don't fail the request
-			log.Printf("Cache set error for %s: %v", cityKey, err)
-		} else {
-			log.Printf("Cached weather data for %s", cityKey)
-		}
+// cacheSet marshals v and stores it under key in the given namespace.
+// Failures are logged, never returned, so a broken cache can't fail an
+// otherwise successful request.
+func cacheSet(ctx context.Context, ns cache.Namespace, key string, at time.Time, v any) {
+	if cacheClient == nil {
+		return
 	}
 
-	return out, nil
+	// Marshal to JSON for caching
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		slog.ErrorContext(ctx, "cache marshal failed", "namespace", ns, "key", key, "err", err)
+	} else if err := cacheClient.Set(ctx, ns, key, at, jsonData); err != nil {
+		// Log error but don't fail the request
+		slog.WarnContext(ctx, "cache set failed", "namespace", ns, "key", key, "err", err)
+	} else {
+		slog.DebugContext(ctx, "cached", "namespace", ns, "key", key, "bytes", len(jsonData))
+	}
 }
 
-func loadWeatherCodes() map[int]string {
-	file := "weather_codes/data.json"
-	data, err := os.ReadFile(file)
+// loadWeatherCodes returns the weather code table, described in locale: the
+// one built into the binary, or the override file configured in weather_codes.
+func loadWeatherCodes(ctx context.Context, locale string) weather_codes.Table {
+	codes, err := weather_codes.CodesFor(locale)
 	if err != nil {
-		// fallback minimal map
-		return map[int]string{
-			0: "Clear sky",
-			1: "Mainly clear",
-			2: "Partly cloudy",
-			3: "Overcast",
-		}
-	}
-	var m map[string]string
-	if err := json.Unmarshal(data, &m); err != nil {
-		return map[int]string{}
-	}
-	out := make(map[int]string)
-	for k, v := range m {
-		var code int
-		fmt.Sscanf(k, "%d", &code)
-		out[code] = v
+		// A broken override file leaves codes undescribed rather than failing requests
+		slog.ErrorContext(ctx, "weather codes unavailable", "err", err)
+		return weather_codes.Table{}
 	}
-	return out
+	return codes
 }
@@ -1,37 +1,129 @@
 package weather_codes
 
 import (
+	_ "embed"
 	"encoding/json"
+	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"sync"
 )
 
-var WEATHER_CODES_FILE_PATH = "./weather_codes/data.json"
+// defaultData is data.json, built into every binary that imports this package.
+//
+//go:embed data.json
+var defaultData []byte
 
-func GetWeatherDescription(weatherCode float32) string {
-	weatherCodesFileData, weatherCodeFileError := os.ReadFile(WEATHER_CODES_FILE_PATH)
+// WEATHER_CODES_FILE_PATH overrides the built-in weather code table with a
+// JSON file of the same shape. Empty uses the embedded data.json.
+var WEATHER_CODES_FILE_PATH = ""
 
-	if weatherCodeFileError != nil {
-		return "Weather codes data unavailable!"
+// codes caches the parsed table for the path it was loaded from.
+var codes struct {
+	sync.Mutex
+	path  string
+	table Table
+}
+
+// Codes returns the weather code table, from WEATHER_CODES_FILE_PATH if set,
+// otherwise from the embedded data.json. The table is parsed once and cached.
+func Codes() (Table, error) {
+	codes.Lock()
+	defer codes.Unlock()
+
+	if codes.table != nil && codes.path == WEATHER_CODES_FILE_PATH {
+		return codes.table, nil
+	}
+
+	table, loadError := loadCodes()
+
+	if loadError != nil {
+		return nil, loadError
+	}
+
+	codes.path = WEATHER_CODES_FILE_PATH
+	codes.table = table
+
+	return table, nil
+}
+
+// loadCodes reads and parses the configured table. An override file may
+// leave out categories, icons and emoji (or be an older description-only
+// file); those are filled in from the embedded table.
+func loadCodes() (Table, error) {
+	builtin, parseError := ParseCodes(defaultData)
+
+	if parseError != nil {
+		return nil, fmt.Errorf("embedded data.json: %w", parseError)
 	}
 
-	var weatherCodesData map[string]string
+	if WEATHER_CODES_FILE_PATH == "" {
+		return builtin, nil
+	}
+
+	fileData, fileError := os.ReadFile(WEATHER_CODES_FILE_PATH)
+
+	if fileError != nil {
+		return nil, fileError
+	}
+
+	table, parseError := ParseCodes(fileData)
+
+	if parseError != nil {
+		return nil, fmt.Errorf("%s: %w", WEATHER_CODES_FILE_PATH, parseError)
+	}
+
+	for code, entry := range table {
+		entry.fillFrom(builtin[code])
+		table[code] = entry
+	}
+
+	return table, nil
+}
+
+// ParseCodes parses a data.json-style {"code": {...}} object. Each value is
+// either a full WeatherCode object or, in older files, just the description.
+func ParseCodes(data []byte) (Table, error) {
+	var raw map[string]WeatherCode
 
 	// Unmarshall the file data
-	unmarshalError := json.Unmarshal(weatherCodesFileData, &weatherCodesData)
+	if unmarshalError := json.Unmarshal(data, &raw); unmarshalError != nil {
+		return nil, unmarshalError
+	}
+
+	table := make(Table, len(raw))
+	for key, entry := range raw {
+		code, convError := strconv.Atoi(key)
+
+		if convError != nil {
+			return nil, fmt.Errorf("invalid weather code %q", key)
+		}
+
+		if entry.Description == "" {
+			return nil, fmt.Errorf("weather code %d has no description", code)
+		}
 
-	if unmarshalError != nil {
-		return "Corrupted weather codes data!"
+		entry.Code = code
+		table[code] = entry
 	}
 
-	weatherCodeKey := strconv.FormatFloat(float64(weatherCode), 'f', 0, 64)
+	if len(table) == 0 {
+		return nil, fmt.Errorf("no weather codes found")
+	}
+
+	return table, nil
+}
 
-	// Check if the key exists in the unmarshalled map, if not the data in the json file might be corrupted.
-	description, ok := weatherCodesData[weatherCodeKey]
+// GetWeatherCode returns the table entry for a weather code as reported by
+// Open-Meteo, described in the given locale. Codes missing from the table
+// come back as Unknown.
+func GetWeatherCode(weatherCode float32, locale string) (WeatherCode, error) {
+	table, loadError := CodesFor(locale)
 
-	if ok {
-		return description
+	if loadError != nil {
+		return WeatherCode{}, loadError
 	}
 
-	return "No matching description found!"
+	return table.Get(int(math.Round(float64(weatherCode)))), nil
 }
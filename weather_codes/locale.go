@@ -0,0 +1,178 @@
+package weather_codes
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the language of data.json, used whenever a request
+// doesn't ask for a locale we have a catalog for.
+const DefaultLocale = "en"
+
+// localeFiles holds one {"code": "description"} catalog per locale,
+// named after the language ("hi.json", "ta.json").
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs caches the parsed locale catalogs. They are embedded, so they
+// are read once and never change.
+var catalogs struct {
+	sync.Once
+	byLocale map[string]map[int]string
+	err      error
+}
+
+func loadCatalogs() (map[string]map[int]string, error) {
+	catalogs.Do(func() {
+		entries, readError := localeFiles.ReadDir("locales")
+
+		if readError != nil {
+			catalogs.err = readError
+			return
+		}
+
+		catalogs.byLocale = make(map[string]map[int]string, len(entries))
+		for _, entry := range entries {
+			data, fileError := localeFiles.ReadFile(path.Join("locales", entry.Name()))
+
+			if fileError != nil {
+				catalogs.err = fileError
+				return
+			}
+
+			table, parseError := ParseCodes(data)
+
+			if parseError != nil {
+				catalogs.err = fmt.Errorf("locales/%s: %w", entry.Name(), parseError)
+				return
+			}
+
+			descriptions := make(map[int]string, len(table))
+			for code, w := range table {
+				descriptions[code] = w.Description
+			}
+			catalogs.byLocale[strings.TrimSuffix(entry.Name(), ".json")] = descriptions
+		}
+	})
+	return catalogs.byLocale, catalogs.err
+}
+
+// Locales lists every supported locale, DefaultLocale first.
+func Locales() []string {
+	byLocale, _ := loadCatalogs()
+
+	locales := make([]string, 0, len(byLocale)+1)
+	for locale := range byLocale {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	return append([]string{DefaultLocale}, locales...)
+}
+
+// CodesFor returns the weather code table with descriptions in the given
+// locale. Codes the locale's catalog doesn't cover, and locales without a
+// catalog, keep the English description.
+func CodesFor(locale string) (Table, error) {
+	table, loadError := Codes()
+
+	if loadError != nil {
+		return nil, loadError
+	}
+
+	if locale == DefaultLocale {
+		return table, nil
+	}
+
+	byLocale, catalogError := loadCatalogs()
+
+	if catalogError != nil {
+		return nil, catalogError
+	}
+
+	descriptions, ok := byLocale[locale]
+
+	if !ok {
+		return table, nil
+	}
+
+	// Copy so the cached English table is never modified
+	localized := make(Table, len(table))
+	for code, w := range table {
+		if description, ok := descriptions[code]; ok {
+			w.Description = description
+		}
+		localized[code] = w
+	}
+
+	return localized, nil
+}
+
+// MatchLocale picks the supported locale that best fits an Accept-Language
+// header ("ta-IN,ta;q=0.9,en;q=0.8"), a single language tag ("hi", "hi-IN")
+// or a POSIX locale ("ta_IN.UTF-8"). Only the language part of a tag is
+// used. Anything we have no catalog for falls back to DefaultLocale.
+func MatchLocale(accept string) string {
+	type candidate struct {
+		locale string
+		q      float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, parseError := strconv.ParseFloat(value, 64)
+
+			if parseError != nil {
+				continue
+			}
+
+			q = parsed
+		}
+
+		if locale := baseLanguage(tag); locale != "" && q > 0 {
+			candidates = append(candidates, candidate{locale: locale, q: q})
+		}
+	}
+
+	// Highest weight first; equal weights keep the order the client sent
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	supported := Locales()
+	for _, c := range candidates {
+		for _, locale := range supported {
+			if c.locale == locale {
+				return locale
+			}
+		}
+	}
+
+	return DefaultLocale
+}
+
+// baseLanguage reduces a language tag to its lowercase primary language:
+// "ta-IN" and "ta_IN.UTF-8" both become "ta". "*" and "" give "".
+func baseLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+
+	if i := strings.IndexAny(tag, "-_."); i >= 0 {
+		tag = tag[:i]
+	}
+
+	if tag == "*" {
+		return ""
+	}
+
+	return tag
+}
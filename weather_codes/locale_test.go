@@ -0,0 +1,80 @@
+package weather_codes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocales(t *testing.T) {
+	if got, want := Locales(), []string{"en", "hi", "ta"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Locales() = %v, want %v", got, want)
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "en"},
+		{"hi", "hi"},
+		{"TA", "ta"},
+		{"hi-IN", "hi"},
+		{"ta_IN.UTF-8", "ta"},
+		{"ta-IN,ta;q=0.9,en;q=0.8", "ta"},
+		// Unsupported languages fall through to the next one the client accepts
+		{"fr-FR,fr;q=0.9,hi;q=0.5", "hi"},
+		{"fr, de-DE", "en"},
+		// Weights beat header order; equal weights keep it
+		{"en;q=0.5, hi;q=0.8", "hi"},
+		{"ta;q=0.8, hi;q=0.8", "ta"},
+		// q=0 means "not this one"; malformed weights are skipped
+		{"hi;q=0, ta", "ta"},
+		{"hi;q=high, ta;q=0.1", "ta"},
+		{"*", "en"},
+		{"*, hi;q=0.1", "hi"},
+		{" ; , ", "en"},
+	}
+	for _, tt := range tests {
+		if got := MatchLocale(tt.accept); got != tt.want {
+			t.Errorf("MatchLocale(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCodesFor(t *testing.T) {
+	withOverride(t, `{"0": "Clear sky", "100": "Locusts"}`)
+
+	english, err := CodesFor("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hindi, err := CodesFor("hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hindi.Get(0).Description; got != "साफ़ आसमान" {
+		t.Errorf("hi description of 0 = %q, want the Hindi catalog's", got)
+	}
+	// Codes the catalog doesn't cover keep the English description
+	if got := hindi.Get(100).Description; got != "Locusts" {
+		t.Errorf("hi description of 100 = %q, want the English fallback", got)
+	}
+	// Translating copies the table; the cached English one is untouched
+	if got := english.Get(0).Description; got != "Clear sky" {
+		t.Errorf("en description of 0 = %q after loading hi", got)
+	}
+	if got := hindi.Get(0).Icon(true); got != english.Get(0).IconDay {
+		t.Errorf("hi icon = %q, want the same icon as en", got)
+	}
+
+	// A locale without a catalog is English
+	french, err := CodesFor("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := french.Get(0).Description; got != "Clear sky" {
+		t.Errorf("fr description of 0 = %q, want the English one", got)
+	}
+}
@@ -0,0 +1,136 @@
+package weather_codes
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Category groups weather codes by the kind of weather they describe.
+type Category string
+
+const (
+	CategoryClear   Category = "clear"
+	CategoryCloud   Category = "cloud"
+	CategoryFog     Category = "fog"
+	CategoryDrizzle Category = "drizzle"
+	CategoryRain    Category = "rain"
+	CategorySnow    Category = "snow"
+	CategoryStorm   Category = "storm"
+)
+
+// Intensity is how strong the weather is within its category, from none
+// (clear sky) to heavy (heavy rain, overcast, dense drizzle).
+type Intensity int
+
+const (
+	IntensityNone Intensity = iota
+	IntensityLight
+	IntensityModerate
+	IntensityHeavy
+)
+
+// WeatherCode is everything we know about one WMO weather code.
+// Icons are identifiers ("partly-cloudy-night"), not images, so each client
+// can draw them its own way while agreeing on which one to show.
+type WeatherCode struct {
+	Code        int       `json:"code"`
+	Description string    `json:"description"`
+	Category    Category  `json:"category"`
+	Intensity   Intensity `json:"intensity"`
+	IconDay     string    `json:"icon_day"`
+	IconNight   string    `json:"icon_night"`
+	Emoji       string    `json:"emoji"`
+
+	// intensitySet records whether the source gave an intensity, since
+	// IntensityNone is a real value and can't mean "not given"
+	intensitySet bool
+}
+
+// Icon returns the day or night icon.
+func (w WeatherCode) Icon(isDay bool) string {
+	if isDay {
+		return w.IconDay
+	}
+	return w.IconNight
+}
+
+// unknownIcon is shown for codes missing from the table.
+const unknownIcon = "unknown"
+
+// Unknown is the WeatherCode reported for a code missing from the table.
+func Unknown(code int) WeatherCode {
+	return WeatherCode{
+		Code:        code,
+		Description: fmt.Sprintf("Unknown code %d", code),
+		IconDay:     unknownIcon,
+		IconNight:   unknownIcon,
+		Emoji:       "❔",
+	}
+}
+
+// Table maps WMO weather codes to their WeatherCode.
+type Table map[int]WeatherCode
+
+// Get returns the entry for code, or Unknown(code) if there isn't one.
+func (t Table) Get(code int) WeatherCode {
+	if w, ok := t[code]; ok {
+		return w
+	}
+	return Unknown(code)
+}
+
+// UnmarshalJSON accepts either a full entry object or, as in older
+// data.json files, just the description string. The caller fills in the
+// rest from the built-in table.
+func (w *WeatherCode) UnmarshalJSON(data []byte) error {
+	var description string
+	if err := json.Unmarshal(data, &description); err == nil {
+		*w = WeatherCode{Description: description}
+		return nil
+	}
+
+	// A plain alias type so decoding the object doesn't recurse back here;
+	// the outer Intensity field wins over the alias's, so a missing intensity
+	// can be told apart from an explicit 0
+	type entry WeatherCode
+	var e struct {
+		entry
+		Intensity *Intensity `json:"intensity"`
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return err
+	}
+	*w = WeatherCode(e.entry)
+	if e.Intensity != nil {
+		w.Intensity, w.intensitySet = *e.Intensity, true
+	}
+	return nil
+}
+
+// fillFrom copies any fields w leaves empty from base. Description-only
+// override files keep their wording but inherit categories and icons.
+func (w *WeatherCode) fillFrom(base WeatherCode) {
+	if w.Category == "" {
+		w.Category = base.Category
+	}
+	if !w.intensitySet {
+		w.Intensity = base.Intensity
+	}
+	if w.IconDay == "" {
+		w.IconDay = base.IconDay
+	}
+	if w.IconNight == "" {
+		w.IconNight = base.IconNight
+	}
+	if w.Emoji == "" {
+		w.Emoji = base.Emoji
+	}
+
+	// Codes the built-in table doesn't know still get an icon to show
+	if w.IconDay == "" {
+		w.IconDay = unknownIcon
+	}
+	if w.IconNight == "" {
+		w.IconNight = w.IconDay
+	}
+}
@@ -0,0 +1,72 @@
+package weather_codes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withOverride points WEATHER_CODES_FILE_PATH at a file holding data for
+// the duration of the test.
+func withOverride(t *testing.T, data string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "codes.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := WEATHER_CODES_FILE_PATH
+	WEATHER_CODES_FILE_PATH = path
+	t.Cleanup(func() { WEATHER_CODES_FILE_PATH = old })
+}
+
+func TestOverrideFillsFromBuiltin(t *testing.T) {
+	withOverride(t, `{
+		"0": "Sunny",
+		"65": {"description": "Downpour", "intensity": 0},
+		"63": {"description": "Rain", "icon_day": "umbrella"},
+		"100": "Locusts"
+	}`)
+
+	table, err := Codes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code int
+		want WeatherCode
+	}{
+		// Description-only: everything else from the built-in entry
+		{0, WeatherCode{Code: 0, Description: "Sunny", Category: CategoryClear, Intensity: IntensityNone, IconDay: "clear-day", IconNight: "clear-night", Emoji: "☀️"}},
+		// An explicit intensity of 0 is kept, not replaced by the built-in heavy
+		{65, WeatherCode{Code: 65, Description: "Downpour", Category: CategoryRain, Intensity: IntensityNone, IconDay: "heavy-rain", IconNight: "heavy-rain", Emoji: "🌧️"}},
+		// A code the built-in table doesn't know still gets an icon
+		{100, WeatherCode{Code: 100, Description: "Locusts", IconDay: unknownIcon, IconNight: unknownIcon}},
+	}
+	for _, tt := range tests {
+		got := table.Get(tt.code)
+		got.intensitySet = false
+		if got != tt.want {
+			t.Errorf("code %d = %+v, want %+v", tt.code, got, tt.want)
+		}
+	}
+
+	// A missing intensity is inherited, and given fields are kept
+	rain := table.Get(63)
+	if rain.Intensity != IntensityModerate || rain.IconDay != "umbrella" || rain.IconNight != "rain" {
+		t.Errorf("code 63 = %+v, want the built-in intensity and night icon with the override's day icon", rain)
+	}
+}
+
+func TestParseCodesErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"not JSON":       `{`,
+		"empty":          `{}`,
+		"bad code":       `{"rain": "Rain"}`,
+		"no description": `{"61": {"category": "rain"}}`,
+	} {
+		if _, err := ParseCodes([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}